@@ -60,3 +60,81 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Create the name of the webhook service
+*/}}
+{{- define "k53.webhookServiceName" -}}
+{{- printf "%s-webhook" (include "k53.fullname" .) | trunc 63 | trimSuffix "-" }}
+{{- end }}
+
+{{/*
+Arguments configuring the AWS session
+*/}}
+{{- define "k53.awsArgs" -}}
+{{- with .Values.aws.region }}
+- --aws-region={{ . }}
+{{- end }}
+{{- with .Values.aws.profile }}
+- --aws-profile={{ . }}
+{{- end }}
+{{- with .Values.aws.endpoints.route53 }}
+- --route53-endpoint={{ . }}
+{{- end }}
+{{- with .Values.aws.endpoints.route53Resolver }}
+- --route53resolver-endpoint={{ . }}
+{{- end }}
+{{- with .Values.aws.endpoints.ec2 }}
+- --ec2-endpoint={{ . }}
+{{- end }}
+{{- with .Values.aws.endpoints.eks }}
+- --eks-endpoint={{ . }}
+{{- end }}
+{{- with .Values.aws.endpoints.imds }}
+- --imds-endpoint={{ . }}
+{{- end }}
+- --route53-qps={{ .Values.aws.route53RateLimit.qps }}
+- --route53-burst={{ .Values.aws.route53RateLimit.burst }}
+{{- with .Values.aws.vpcID }}
+- --vpc-id={{ . }}
+{{- end }}
+{{- with .Values.aws.eksClusterName }}
+- --eks-cluster-name={{ . }}
+{{- end }}
+{{- with .Values.aws.zoneRole.roleARN }}
+- --zone-role-arn={{ . }}
+{{- end }}
+{{- with .Values.aws.zoneRole.externalID }}
+- --zone-role-external-id={{ . }}
+{{- end }}
+{{- with .Values.aws.zoneRole.sessionName }}
+- --zone-role-session-name={{ . }}
+{{- end }}
+{{- range $zone, $role := .Values.aws.zoneRoles }}
+- --zone-role={{ $zone }}={{ $role.roleARN }}{{ with $role.externalID }},{{ . }}{{ end }}
+{{- end }}
+{{- end }}
+
+{{/*
+Environment variables holding static AWS credentials
+*/}}
+{{- define "k53.awsEnv" -}}
+{{- with .Values.aws.credentialsSecretName }}
+- name: AWS_ACCESS_KEY_ID
+  valueFrom:
+    secretKeyRef:
+      name: {{ . }}
+      key: accessKeyID
+- name: AWS_SECRET_ACCESS_KEY
+  valueFrom:
+    secretKeyRef:
+      name: {{ . }}
+      key: secretAccessKey
+- name: AWS_SESSION_TOKEN
+  valueFrom:
+    secretKeyRef:
+      name: {{ . }}
+      key: sessionToken
+      optional: true
+{{- end }}
+{{- end }}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwagner5/k53/pkg/session"
+)
+
+// awsFlags registers the flags configuring the AWS session, shared by the controller and the cleanup subcommand
+func awsFlags(flags *flag.FlagSet) *session.Options {
+	opts := &session.Options{}
+	flags.StringVar(&opts.Region, "aws-region", "", "The AWS region, defaults to the environment or the region of the EC2 instance.")
+	flags.StringVar(&opts.Profile, "aws-profile", "", "The AWS shared config profile to use.")
+	flags.StringVar(&opts.AccessKeyID, "aws-access-key-id", "", "A static AWS access key id, prefer the AWS_ACCESS_KEY_ID environment variable to keep it out of the process list.")
+	flags.StringVar(&opts.SecretAccessKey, "aws-secret-access-key", "", "The secret of the static AWS access key, prefer the AWS_SECRET_ACCESS_KEY environment variable.")
+	flags.StringVar(&opts.SessionToken, "aws-session-token", "", "The session token of temporary static AWS credentials, prefer the AWS_SESSION_TOKEN environment variable.")
+	flags.StringVar(&opts.Route53Endpoint, "route53-endpoint", "", "Overrides the Route 53 endpoint URL, e.g. for a local emulator.")
+	flags.StringVar(&opts.Route53ResolverEndpoint, "route53resolver-endpoint", "", "Overrides the Route 53 Resolver endpoint URL.")
+	flags.StringVar(&opts.EC2Endpoint, "ec2-endpoint", "", "Overrides the EC2 endpoint URL.")
+	flags.StringVar(&opts.EKSEndpoint, "eks-endpoint", "", "Overrides the EKS endpoint URL.")
+	flags.Float64Var(&opts.Route53QPS, "route53-qps", 3, "The maximum number of Route 53 requests per second, leaving room for other tools sharing the account's limit of 5. 0 disables the limit.")
+	flags.IntVar(&opts.Route53Burst, "route53-burst", 5, "The maximum burst of Route 53 requests.")
+	flags.StringVar(&opts.IMDSEndpoint, "imds-endpoint", "", "Overrides the EC2 Instance Metadata Service endpoint URL.")
+	return opts
+}
+
+// zoneRoleFlags registers the flags configuring the role managing the hosted zone from another account, and the roles
+// overriding it per zone
+func zoneRoleFlags(flags *flag.FlagSet) (*session.RoleOptions, zoneRoles) {
+	role := &session.RoleOptions{}
+	roles := zoneRoles{}
+	flags.StringVar(&role.RoleARN, "zone-role-arn", "", "The ARN of the role to assume to manage a private hosted zone in another account.")
+	flags.StringVar(&role.ExternalID, "zone-role-external-id", "", "The external id required by the trust policy of the zone role.")
+	flags.StringVar(&role.SessionName, "zone-role-session-name", "k53", "The session name used when assuming the zone role.")
+	flags.Var(roles, "zone-role", "The role managing a single private hosted zone as <zone>=<role-arn>[,<external-id>], overriding --zone-role-arn for that zone. Can be repeated.")
+	return role, roles
+}
+
+// zoneRoles maps the names of private hosted zones to the role managing them
+type zoneRoles map[string]session.RoleOptions
+
+func (z zoneRoles) String() string {
+	var roles []string
+	for zoneName, role := range z {
+		roles = append(roles, fmt.Sprintf("%s=%s", zoneName, role.RoleARN))
+	}
+	sort.Strings(roles)
+	return strings.Join(roles, " ")
+}
+
+func (z zoneRoles) Set(value string) error {
+	zoneName, role, ok := strings.Cut(value, "=")
+	if !ok || zoneName == "" || role == "" {
+		return fmt.Errorf("expected <zone>=<role-arn>[,<external-id>], got %q", value)
+	}
+	roleARN, externalID, _ := strings.Cut(role, ",")
+	z[zoneName] = session.RoleOptions{RoleARN: roleARN, ExternalID: externalID}
+	return nil
+}
+
+// vpcFlags registers the flags configuring how the cluster's VPC is discovered
+func vpcFlags(flags *flag.FlagSet) *session.VPCOptions {
+	opts := &session.VPCOptions{}
+	flags.StringVar(&opts.VPCID, "vpc-id", "", "The id of the cluster's VPC, discovered if not set.")
+	flags.StringVar(&opts.EKSClusterName, "eks-cluster-name", "", "The name of the EKS cluster, used to discover its VPC with EKS or the cluster's EC2 tags instead of EC2 IMDS.")
+	return opts
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+
+	"k8s.io/klog/v2"
+
+	"github.com/bwagner5/k53/pkg/session"
+	"github.com/bwagner5/k53/pkg/zone"
+)
+
+// cleanup deletes the records and CIDR collection this cluster owns in the private hosted zone, and optionally the
+// hosted zone itself. It is run by the chart's pre-delete hook when k53 is uninstalled.
+func cleanup(args []string) int {
+	flags := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	clusterName := flags.String("cluster-name", "k53", "The name identifying this cluster's records in a hosted zone shared with other clusters.")
+	zoneName := flags.String("zone-name", "cluster-test.local", "The name of the Route 53 private hosted zone records are published in.")
+	deleteZone := flags.Bool("delete-hosted-zone", false, "Delete the private hosted zone once no other cluster publishes records in it, or else disassociate this cluster's VPC from it.")
+	dryRun := flags.Bool("dry-run", false, "Log what would be cleaned up instead of deleting it, also enabled by the zone tag "+zone.DryRunTag+"=true.")
+	syncPolicy := flags.String("sync-policy", zone.SyncPolicySync, "Only clean up with the sync policy "+zone.SyncPolicySync+", which the zone tag "+zone.SyncPolicyTag+" overrides.")
+	awsOpts := awsFlags(flags)
+	zoneRole, zoneRoles := zoneRoleFlags(flags)
+	vpcOpts := vpcFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	ctx := context.Background()
+	sess, err := session.Create(ctx, version, *awsOpts)
+	if err != nil {
+		klog.Errorf("unable to create aws session: %v", err)
+		return 1
+	}
+	zoneReconciler, err := zone.New(nil, sess, zone.Options{
+		ClusterName:         *clusterName,
+		ZoneName:            *zoneName,
+		PodNameTemplate:     zone.DefaultPodNameTemplate,
+		ServiceNameTemplate: zone.DefaultServiceNameTemplate,
+		InvalidNamePolicy:   zone.InvalidNamePolicySanitize,
+		AssumeRole:          *zoneRole,
+		ZoneRoles:           zoneRoles,
+		VPC:                 session.NewVPC(sess, *vpcOpts),
+		DryRun:              *dryRun,
+		SyncPolicy:          *syncPolicy,
+	})
+	if err != nil {
+		klog.Errorf("invalid cleanup options: %v", err)
+		return 1
+	}
+	if err := zoneReconciler.Cleanup(ctx, *deleteZone); err != nil {
+		klog.Errorf("unable to clean up: %v", err)
+		return 1
+	}
+	return 0
+}
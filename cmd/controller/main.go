@@ -20,6 +20,10 @@ import (
 	"context"
 	"flag"
 	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/route53resolver"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -27,14 +31,22 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/klog/v2"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+	srcv1alpha1 "github.com/bwagner5/k53/pkg/api/v1alpha1"
+	"github.com/bwagner5/k53/pkg/crd"
+	"github.com/bwagner5/k53/pkg/dnsconfig"
 	"github.com/bwagner5/k53/pkg/resolver"
 	"github.com/bwagner5/k53/pkg/session"
 	"github.com/bwagner5/k53/pkg/zone"
@@ -51,17 +63,66 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(srcv1.AddToScheme(scheme))
+	utilruntime.Must(srcv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cleanup" {
+		os.Exit(cleanup(os.Args[2:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var clusterName string
+	var zoneName string
+	var podNameTemplate string
+	var serviceNameTemplate string
+	var invalidNamePolicy string
+	var fqdnAnnotation bool
+	var adoptRecords bool
+	var dryRun bool
+	var syncPolicy string
+	var batchWindow time.Duration
+	var maxBatchSize int
+	var maxDeleteCount int
+	var maxDeletePercent int
+	var minDeletesForPercent int
+	var enableWebhooks bool
+	var webhookPort int
+	var webhookCertDir string
+	var enablePodDNSInjection bool
+	var webhookServiceName string
+	var webhookServiceNamespace string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&clusterName, "cluster-name", "k53", "The name identifying this cluster's records in a hosted zone shared with other clusters.")
+	flag.StringVar(&zoneName, "zone-name", "cluster-test.local", "The name of the Route 53 private hosted zone to publish records in.")
+	flag.StringVar(&podNameTemplate, "pod-name-template", zone.DefaultPodNameTemplate, "The Go template rendering pod record names relative to the zone.")
+	flag.StringVar(&serviceNameTemplate, "service-name-template", zone.DefaultServiceNameTemplate, "The Go template rendering service record names relative to the zone.")
+	flag.StringVar(&invalidNamePolicy, "invalid-name-policy", zone.InvalidNamePolicySanitize, "How to handle generated record names that are not valid DNS names, either reject or sanitize.")
+	flag.BoolVar(&adoptRecords, "adopt-records", false, "Claim the records nobody owns that are named like the records of the pod and service name templates, e.g. those published before owner records existed, so that they are deleted once no longer generated. Only applies with sync policy sync.")
+	flag.BoolVar(&fqdnAnnotation, "fqdn-annotation", false, "Annotate published Pods and Services with their fully qualified names in "+zone.FQDNAnnotation+".")
+	flag.BoolVar(&dryRun, "dry-run", false, "Log the changes to the private hosted zone instead of applying them. Dry-run can also be enabled per zone with the hosted zone tag "+zone.DryRunTag+"=true.")
+	flag.StringVar(&syncPolicy, "sync-policy", zone.SyncPolicySync, "How records are synced to the private hosted zone, one of sync, upsert-only or create-only. It can be overridden per zone with the hosted zone tag "+zone.SyncPolicyTag+".")
+	flag.DurationVar(&batchWindow, "batch-window", time.Second, "How long events are accumulated before the records are synced in one Route 53 change batch. 0 syncs every event right away.")
+	flag.IntVar(&maxBatchSize, "max-batch-size", 500, "The number of pending Kubernetes events that syncs the records before the batch window ends, 0 disables it. The changes of a sync are split into Route 53 change batches of at most 1000 records regardless.")
+	flag.IntVar(&maxDeleteCount, "max-delete-count", 0, "The maximum number of records deleted per reconcile, 0 for no limit. Deletions exceeding it are withheld while records are still upserted.")
+	flag.IntVar(&maxDeletePercent, "max-delete-percent", 50, "The maximum percentage of the cluster's records deleted per reconcile, 0 for no limit. It applies from --min-deletes-for-percent deletions on.")
+	flag.IntVar(&minDeletesForPercent, "min-deletes-for-percent", 10, "The number of deletions per reconcile from which --max-delete-percent applies, so that small zones can still lose most of their records.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the defaulting and validating webhooks of the k53 CRDs.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the webhook server listens on.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "", "The directory holding the tls.crt and tls.key of the webhook server, defaults to <tmp>/k8s-webhook-server/serving-certs.")
+	flag.StringVar(&webhookServiceName, "webhook-service-name", "k53-webhook", "The name of the Service fronting the webhook server, used to configure the conversion webhook of the k53 CRDs.")
+	flag.StringVar(&webhookServiceNamespace, "webhook-service-namespace", "k53", "The namespace of the Service fronting the webhook server.")
+	flag.BoolVar(&enablePodDNSInjection, "enable-pod-dns-injection", false, "Serve the webhook pointing pods of opted in namespaces at the VPC resolver.")
+	awsOpts := awsFlags(flag.CommandLine)
+	zoneRole, zoneRoles := zoneRoleFlags(flag.CommandLine)
+	vpcOpts := vpcFlags(flag.CommandLine)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -75,7 +136,8 @@ func main() {
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
+		Port:                   webhookPort,
+		CertDir:                webhookCertDir,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "997fc2.bwag.me",
@@ -86,15 +148,101 @@ func main() {
 		os.Exit(1)
 	}
 
+	sess, err := session.Create(ctx, version, *awsOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to create aws session")
+		os.Exit(1)
+	}
+	vpc := session.NewVPC(sess, *vpcOpts)
 	if err = (&resolver.Reconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Session:         sess,
+		Route53Resolver: route53resolver.New(sess),
+		VPC:             vpc,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DNS")
 		os.Exit(1)
 	}
 
-	if err := zone.New(mgr.GetClient(), session.Create(ctx, version)).SetupWithManager(mgr); err != nil {
+	if enableWebhooks {
+		if err := (&srcv1.Resolver{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Resolver")
+			os.Exit(1)
+		}
+		// the manager's client only serves reads once the manager started
+		directClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+		if err != nil {
+			setupLog.Error(err, "unable to create client")
+			os.Exit(1)
+		}
+		certDir := webhookCertDir
+		if certDir == "" {
+			certDir = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+		}
+		webhookService := crd.WebhookService{
+			Name:      webhookServiceName,
+			Namespace: webhookServiceNamespace,
+			Port:      443,
+		}
+		if err := crd.EnsureConversionWebhook(ctx, directClient, webhookService, certDir); err != nil {
+			setupLog.Error(err, "unable to configure conversion webhook")
+			os.Exit(1)
+		}
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return crd.KeepConversionWebhook(ctx, directClient, webhookService, certDir, time.Minute)
+		})); err != nil {
+			setupLog.Error(err, "unable to set up conversion webhook sync")
+			os.Exit(1)
+		}
+		// stored resolvers can only be migrated while this webhook server converts them
+		webhookStarted := func() error { return mgr.GetWebhookServer().StartedChecker()(nil) }
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return crd.RunStorageMigration(ctx, mgr.GetAPIReader(), mgr.GetClient(), webhookStarted, 10*time.Second)
+		})); err != nil {
+			setupLog.Error(err, "unable to set up storage migration")
+			os.Exit(1)
+		}
+	}
+
+	if enablePodDNSInjection {
+		injector, err := dnsconfig.New(ctx, sess, vpc, dnsconfig.Options{
+			ZoneName:            zoneName,
+			ClusterName:         clusterName,
+			ServiceNameTemplate: serviceNameTemplate,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PodDNSConfig")
+			os.Exit(1)
+		}
+		mgr.GetWebhookServer().Register(dnsconfig.Path, &webhook.Admission{Handler: injector})
+	}
+
+	zoneReconciler, err := zone.New(mgr.GetClient(), sess, zone.Options{
+		ClusterName:          clusterName,
+		ZoneName:             zoneName,
+		PodNameTemplate:      podNameTemplate,
+		ServiceNameTemplate:  serviceNameTemplate,
+		InvalidNamePolicy:    invalidNamePolicy,
+		AssumeRole:           *zoneRole,
+		ZoneRoles:            zoneRoles,
+		VPC:                  vpc,
+		FQDNAnnotation:       fqdnAnnotation,
+		AdoptRecords:         adoptRecords,
+		DryRun:               dryRun,
+		SyncPolicy:           syncPolicy,
+		BatchWindow:          batchWindow,
+		MaxBatchSize:         maxBatchSize,
+		MaxDeleteCount:       maxDeleteCount,
+		MaxDeletePercent:     maxDeletePercent,
+		MinDeletesForPercent: minDeletesForPercent,
+		ControllerPod:        types.NamespacedName{Namespace: os.Getenv("POD_NAMESPACE"), Name: os.Getenv("POD_NAME")},
+	})
+	if err != nil {
+		setupLog.Error(err, "invalid zone controller options")
+		os.Exit(1)
+	}
+	if err := zoneReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Zone")
 		os.Exit(1)
 	}
@@ -20,20 +20,303 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// ConditionTypeReady reports whether a Resolver, or one of its AWS resources, is reconciled
+	ConditionTypeReady = "Ready"
+	// ConditionTypeDegraded reports that reconciling a Resolver failed after it was ready, so its AWS resources may
+	// still reflect an earlier generation of the spec
+	ConditionTypeDegraded = "Degraded"
+	// ConditionTypeQueryLogging reports the state of the query log config of a Resolver
+	ConditionTypeQueryLogging = "QueryLogging"
+	// ConditionTypeForwarding reports the state of the outbound endpoint and forwarding rules of a Resolver
+	ConditionTypeForwarding = "Forwarding"
+	// ConditionTypeFirewall reports the state of the DNS Firewall domain lists and rule groups of a Resolver
+	ConditionTypeFirewall = "Firewall"
+	// ConditionTypeInboundEndpoint reports the state of the inbound endpoint of a Resolver
+	ConditionTypeInboundEndpoint = "InboundEndpoint"
+	// ConditionTypeAssumeRole reports whether the role of a Resolver could be assumed
+	ConditionTypeAssumeRole = "AssumeRole"
+	// ConditionTypeConflict reports that another Resolver is the effective Resolver of the cluster,
+	// so the AWS resources of this Resolver are not reconciled
+	ConditionTypeConflict = "Conflict"
+)
+
 // ResolverSpec defines the desired state of cluster dns
 type ResolverSpec struct {
-	// QueryLogConfig configure the DNS query log configuration
-	QueryLogConfig string `json:"queryLogging"`
+	// AssumeRole is the role managing the Route 53 Resolver resources, e.g. in a central networking account
+	// the cluster's VPC is shared from
+	// +optional
+	AssumeRole *AssumeRole `json:"assumeRole,omitempty"`
+	// QueryLogging is the ARN of the destination DNS query logs are sent to, query logging is disabled when empty.
+	// Deprecated: use QueryLogConfig, QueryLogging is only kept for Resolvers created before it.
+	// +optional
+	QueryLogging string `json:"queryLogging,omitempty"`
+	// QueryLogConfig configures Route 53 Resolver query logging for the cluster's VPC
+	// +optional
+	QueryLogConfig *QueryLogConfig `json:"queryLogConfig,omitempty"`
+	// OutboundEndpoint is the Route 53 Resolver endpoint queries matching Forward rules are sent through
+	// +optional
+	OutboundEndpoint *ResolverEndpoint `json:"outboundEndpoint,omitempty"`
+	// InboundEndpoint is the Route 53 Resolver endpoint networks outside of the VPC, e.g. on-premises over Direct Connect,
+	// send queries for the cluster's private hosted zone to
+	// +optional
+	InboundEndpoint *ResolverEndpoint `json:"inboundEndpoint,omitempty"`
+	// ForwardingRules forward the DNS queries for domains outside of the cluster, replacing CoreDNS stub domains and upstreams
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ForwardingRules []ForwardingRule `json:"forwardingRules,omitempty"`
+	// FirewallDomainLists are the lists of domains matched by FirewallRuleGroups
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	FirewallDomainLists []FirewallDomainList `json:"firewallDomainLists,omitempty"`
+	// FirewallRuleGroups are the Route 53 Resolver DNS Firewall rule groups filtering the DNS queries made from the cluster's VPC
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	FirewallRuleGroups []FirewallRuleGroup `json:"firewallRuleGroups,omitempty"`
+}
+
+// EffectiveQueryLogConfig returns the QueryLogConfig, or else the config of the deprecated QueryLogging destination
+func (s *ResolverSpec) EffectiveQueryLogConfig() *QueryLogConfig {
+	if s.QueryLogConfig != nil {
+		return s.QueryLogConfig
+	}
+	if s.QueryLogging != "" {
+		return &QueryLogConfig{DestinationARN: s.QueryLogging}
+	}
+	return nil
+}
+
+// AssumeRole defines an IAM role to assume
+type AssumeRole struct {
+	// RoleARN is the ARN of the role
+	// +kubebuilder:validation:Pattern=`^arn:[^:]+:iam::[0-9]{12}:role/`
+	RoleARN string `json:"roleARN"`
+	// ExternalID is the external id required by the trust policy of the role
+	// +optional
+	ExternalID string `json:"externalID,omitempty"`
+	// SessionName is the name of the role session, defaults to k53
+	// +kubebuilder:validation:Pattern=`^[\w+=,.@-]{2,64}$`
+	// +optional
+	SessionName string `json:"sessionName,omitempty"`
+}
+
+// QueryLogConfig defines where the DNS queries made from the cluster's VPC are logged
+type QueryLogConfig struct {
+	// DestinationARN is the ARN of the CloudWatch Logs log group, S3 bucket or Kinesis Data Firehose delivery stream
+	// that query logs are sent to
+	// +kubebuilder:validation:Pattern=`^arn:[^:]+:(logs|s3|firehose):`
+	DestinationARN string `json:"destinationARN"`
+}
+
+// ResolverEndpoint defines a Route 53 Resolver endpoint in the cluster's VPC
+type ResolverEndpoint struct {
+	// SubnetIDs are the subnets the endpoint gets an IP address in, at least two in different availability zones
+	// +kubebuilder:validation:MinItems=2
+	SubnetIDs []string `json:"subnetIDs"`
+	// SecurityGroupIDs are the security groups controlling the traffic to and from the endpoint
+	// +kubebuilder:validation:MinItems=1
+	SecurityGroupIDs []string `json:"securityGroupIDs"`
+	// IPAddresses pin the IP addresses the endpoint gets in its subnets, subnets without a pinned IP address get one assigned
+	// +optional
+	IPAddresses []EndpointIPAddress `json:"ipAddresses,omitempty"`
+}
+
+// EndpointIPAddress is an IP address of a Route 53 Resolver endpoint
+type EndpointIPAddress struct {
+	// SubnetID is the subnet of the IP address, one of the SubnetIDs of the endpoint
+	SubnetID string `json:"subnetID"`
+	// IP is the IPv4 address within the subnet
+	IP string `json:"ip"`
+}
+
+// ForwardingRule defines how the DNS queries for a domain are resolved
+type ForwardingRule struct {
+	// Name identifies the rule within the Resolver
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=32
+	Name string `json:"name"`
+	// DomainName is the domain the rule applies to, including its subdomains
+	DomainName string `json:"domainName"`
+	// RuleType is Forward to send queries to TargetIPs through the OutboundEndpoint,
+	// or System to resolve them with the VPC resolver, e.g. for a subdomain of a forwarded domain
+	// +kubebuilder:validation:Enum=Forward;System
+	// +kubebuilder:default=Forward
+	// +optional
+	RuleType string `json:"ruleType,omitempty"`
+	// TargetIPs are the DNS servers queries are forwarded to
+	// +optional
+	TargetIPs []TargetAddress `json:"targetIPs,omitempty"`
 }
 
-// DNSStatus defines the observed state of the cluster DNS zone
+// TargetAddress is a DNS server queries are forwarded to
+type TargetAddress struct {
+	// IP is the IPv4 address of the DNS server
+	IP string `json:"ip"`
+	// Port is the port of the DNS server
+	// +kubebuilder:default=53
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int64 `json:"port,omitempty"`
+}
+
+// FirewallDomainList is a list of domains DNS Firewall rules match
+type FirewallDomainList struct {
+	// Name identifies the domain list within the Resolver
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=32
+	Name string `json:"name"`
+	// Domains are the domains in the list, a leading "*." matches every subdomain
+	// +kubebuilder:validation:MinItems=1
+	Domains []string `json:"domains"`
+}
+
+// FirewallRuleGroup is a DNS Firewall rule group associated with the cluster's VPC
+type FirewallRuleGroup struct {
+	// Name identifies the rule group within the Resolver
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=32
+	Name string `json:"name"`
+	// Priority orders the rule groups associated with the VPC, lower priorities are evaluated first
+	// +kubebuilder:validation:Minimum=101
+	// +kubebuilder:validation:Maximum=9900
+	Priority int64 `json:"priority"`
+	// Rules are evaluated in order, the first rule matching a query decides its fate
+	// +kubebuilder:validation:MinItems=1
+	Rules []FirewallRule `json:"rules"`
+}
+
+// FirewallRule allows, blocks or alerts on the DNS queries for the domains of a domain list
+type FirewallRule struct {
+	// Name identifies the rule within the rule group
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=32
+	Name string `json:"name"`
+	// DomainList is the name of the FirewallDomainList the rule matches
+	DomainList string `json:"domainList"`
+	// Action is taken on queries matching the rule
+	// +kubebuilder:validation:Enum=Allow;Block;Alert
+	Action string `json:"action"`
+	// BlockResponse is the response to blocked queries
+	// +kubebuilder:validation:Enum=NoData;NXDomain;Override
+	// +kubebuilder:default=NoData
+	// +optional
+	BlockResponse string `json:"blockResponse,omitempty"`
+	// BlockOverrideDomain is the domain returned as a CNAME to blocked queries when BlockResponse is Override
+	// +optional
+	BlockOverrideDomain *string `json:"blockOverrideDomain,omitempty"`
+	// BlockOverrideTTL is the TTL in seconds of the CNAME returned when BlockResponse is Override
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=604800
+	// +optional
+	BlockOverrideTTL *int64 `json:"blockOverrideTTL,omitempty"`
+}
+
+// ResolverStatus defines the observed state of a Resolver
 type ResolverStatus struct {
-	State *string `json:"state,omitempty"`
+	// ObservedGeneration is the generation of the spec the status was last reconciled from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions report whether the Resolver is Ready or Degraded, and the state of each of its features:
+	// QueryLogging, Forwarding, Firewall and InboundEndpoint
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastError is the error of the last reconcile, cleared once the Resolver reconciles successfully
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+	// QueryLogConfigID is the id of the Route 53 Resolver query log config
+	// +optional
+	QueryLogConfigID *string `json:"queryLogConfigID,omitempty"`
+	// QueryLogConfigAssociationID is the id of the association of the query log config with the cluster's VPC
+	// +optional
+	QueryLogConfigAssociationID *string `json:"queryLogConfigAssociationID,omitempty"`
+	// OutboundEndpointID is the id of the outbound Route 53 Resolver endpoint
+	// +optional
+	OutboundEndpointID *string `json:"outboundEndpointID,omitempty"`
+	// InboundEndpointID is the id of the inbound Route 53 Resolver endpoint
+	// +optional
+	InboundEndpointID *string `json:"inboundEndpointID,omitempty"`
+	// InboundEndpointIPs are the IP addresses of the inbound endpoint that conditional forwarders send queries to
+	// +optional
+	InboundEndpointIPs []string `json:"inboundEndpointIPs,omitempty"`
+	// ForwardingRules is the observed state of each forwarding rule
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ForwardingRules []ForwardingRuleStatus `json:"forwardingRules,omitempty"`
+	// FirewallDomainLists are the ids of the DNS Firewall domain lists
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	FirewallDomainLists []FirewallDomainListStatus `json:"firewallDomainLists,omitempty"`
+	// FirewallRuleGroups is the observed state of each DNS Firewall rule group
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	FirewallRuleGroups []FirewallRuleGroupStatus `json:"firewallRuleGroups,omitempty"`
+}
+
+// FirewallDomainListStatus defines the observed state of a DNS Firewall domain list
+type FirewallDomainListStatus struct {
+	// Name is the name of the domain list in the Resolver spec
+	Name string `json:"name"`
+	// DomainListID is the id of the Route 53 Resolver DNS Firewall domain list
+	// +optional
+	DomainListID *string `json:"domainListID,omitempty"`
+}
+
+// FirewallRuleGroupStatus defines the observed state of a DNS Firewall rule group
+type FirewallRuleGroupStatus struct {
+	// Name is the name of the rule group in the Resolver spec
+	Name string `json:"name"`
+	// RuleGroupID is the id of the Route 53 Resolver DNS Firewall rule group
+	// +optional
+	RuleGroupID *string `json:"ruleGroupID,omitempty"`
+	// AssociationID is the id of the association of the rule group with the cluster's VPC
+	// +optional
+	AssociationID *string `json:"associationID,omitempty"`
+	// AssociationStatus is the status of the association reported by Route 53 Resolver, e.g. COMPLETE or UPDATING
+	// +optional
+	AssociationStatus *string `json:"associationStatus,omitempty"`
+	// Conditions report whether the rule group is ready
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ForwardingRuleStatus defines the observed state of a forwarding rule
+type ForwardingRuleStatus struct {
+	// Name is the name of the rule in the Resolver spec
+	Name string `json:"name"`
+	// RuleID is the id of the Route 53 Resolver rule
+	// +optional
+	RuleID *string `json:"ruleID,omitempty"`
+	// AssociationID is the id of the association of the rule with the cluster's VPC
+	// +optional
+	AssociationID *string `json:"associationID,omitempty"`
+	// Conditions report whether the rule is ready
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:resource:path=resolvers
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Degraded",type=string,JSONPath=`.status.conditions[?(@.type=="Degraded")].status`
+//+kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+//+kubebuilder:printcolumn:name="Inbound IPs",type=string,JSONPath=`.status.inboundEndpointIPs`,priority=1
+//+kubebuilder:printcolumn:name="Error",type=string,JSONPath=`.status.lastError`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Resolver is the Schema for the repos API
 type Resolver struct {
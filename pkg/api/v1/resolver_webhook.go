@@ -0,0 +1,244 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of Resolvers with the manager
+func (r *Resolver) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-src-bwag-me-v1-resolver,mutating=true,failurePolicy=fail,sideEffects=None,groups=src.bwag.me,resources=resolvers,verbs=create;update,versions=v1,name=mresolver.src.bwag.me,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &Resolver{}
+
+// Default fills in the defaults the CRD schema can not express and normalizes domain names, so that the spec
+// compares equal to what Route 53 Resolver reports
+func (r *Resolver) Default() {
+	for i := range r.Spec.ForwardingRules {
+		rule := &r.Spec.ForwardingRules[i]
+		rule.DomainName = normalizeDomain(rule.DomainName)
+		if rule.RuleType == "" {
+			rule.RuleType = "Forward"
+		}
+		for j := range rule.TargetIPs {
+			if rule.TargetIPs[j].Port == nil {
+				port := int64(53)
+				rule.TargetIPs[j].Port = &port
+			}
+		}
+	}
+	for i := range r.Spec.FirewallDomainLists {
+		for j, domain := range r.Spec.FirewallDomainLists[i].Domains {
+			r.Spec.FirewallDomainLists[i].Domains[j] = normalizeDomain(domain)
+		}
+	}
+	for i := range r.Spec.FirewallRuleGroups {
+		for j := range r.Spec.FirewallRuleGroups[i].Rules {
+			rule := &r.Spec.FirewallRuleGroups[i].Rules[j]
+			if rule.Action != "Block" {
+				rule.BlockResponse = ""
+				continue
+			}
+			if rule.BlockResponse == "" {
+				rule.BlockResponse = "NoData"
+			}
+			if rule.BlockResponse == "Override" && rule.BlockOverrideTTL == nil {
+				ttl := int64(300)
+				rule.BlockOverrideTTL = &ttl
+			}
+		}
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-src-bwag-me-v1-resolver,mutating=false,failurePolicy=fail,sideEffects=None,groups=src.bwag.me,resources=resolvers,verbs=create;update,versions=v1,name=vresolver.src.bwag.me,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Resolver{}
+
+// ValidateCreate checks the parts of the spec the CRD schema can not, e.g. references between fields
+func (r *Resolver) ValidateCreate() error {
+	return r.toError(r.validateSpec())
+}
+
+// ValidateUpdate also rejects changes Route 53 Resolver can not apply to existing resources
+func (r *Resolver) ValidateUpdate(old runtime.Object) error {
+	errs := r.validateSpec()
+	if oldResolver, ok := old.(*Resolver); ok {
+		errs = append(errs, validateEndpointUpdate(field.NewPath("spec", "outboundEndpoint"), oldResolver.Spec.OutboundEndpoint, r.Spec.OutboundEndpoint)...)
+		errs = append(errs, validateEndpointUpdate(field.NewPath("spec", "inboundEndpoint"), oldResolver.Spec.InboundEndpoint, r.Spec.InboundEndpoint)...)
+	}
+	return r.toError(errs)
+}
+
+// ValidateDelete allows all deletions, the AWS resources are cleaned up by the finalizer
+func (r *Resolver) ValidateDelete() error {
+	return nil
+}
+
+func (r *Resolver) toError(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Resolver").GroupKind(), r.Name, errs)
+}
+
+func (r *Resolver) validateSpec() field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+	if r.Spec.QueryLogging != "" {
+		errs = append(errs, validateDestinationARN(spec.Child("queryLogging"), r.Spec.QueryLogging)...)
+		if r.Spec.QueryLogConfig != nil {
+			errs = append(errs, field.Forbidden(spec.Child("queryLogging"), "queryLogging is deprecated and may not be set together with queryLogConfig"))
+		}
+	}
+	if r.Spec.QueryLogConfig != nil {
+		errs = append(errs, validateDestinationARN(spec.Child("queryLogConfig", "destinationARN"), r.Spec.QueryLogConfig.DestinationARN)...)
+	}
+	errs = append(errs, validateEndpoint(spec.Child("outboundEndpoint"), r.Spec.OutboundEndpoint)...)
+	errs = append(errs, validateEndpoint(spec.Child("inboundEndpoint"), r.Spec.InboundEndpoint)...)
+
+	domains := map[string]bool{}
+	for i, rule := range r.Spec.ForwardingRules {
+		path := spec.Child("forwardingRules").Index(i)
+		domain := normalizeDomain(rule.DomainName)
+		for _, msg := range validation.IsDNS1123Subdomain(domain) {
+			errs = append(errs, field.Invalid(path.Child("domainName"), rule.DomainName, msg))
+		}
+		if domains[domain] {
+			errs = append(errs, field.Duplicate(path.Child("domainName"), rule.DomainName))
+		}
+		domains[domain] = true
+		switch rule.RuleType {
+		case "System":
+			if len(rule.TargetIPs) > 0 {
+				errs = append(errs, field.Forbidden(path.Child("targetIPs"), "System rules resolve queries with the VPC resolver and take no target IPs"))
+			}
+		default:
+			if len(rule.TargetIPs) == 0 {
+				errs = append(errs, field.Required(path.Child("targetIPs"), "Forward rules need at least one target IP"))
+			}
+			if r.Spec.OutboundEndpoint == nil {
+				errs = append(errs, field.Required(spec.Child("outboundEndpoint"), fmt.Sprintf("forwarding rule %s forwards queries through the outbound endpoint", rule.Name)))
+			}
+		}
+		for j, target := range rule.TargetIPs {
+			if ip := net.ParseIP(target.IP); ip == nil || ip.To4() == nil {
+				errs = append(errs, field.Invalid(path.Child("targetIPs").Index(j).Child("ip"), target.IP, "must be an IPv4 address"))
+			}
+		}
+	}
+
+	domainLists := map[string]bool{}
+	for _, list := range r.Spec.FirewallDomainLists {
+		domainLists[list.Name] = true
+	}
+	priorities := map[int64]bool{}
+	for i, group := range r.Spec.FirewallRuleGroups {
+		path := spec.Child("firewallRuleGroups").Index(i)
+		if priorities[group.Priority] {
+			errs = append(errs, field.Duplicate(path.Child("priority"), group.Priority))
+		}
+		priorities[group.Priority] = true
+		for j, rule := range group.Rules {
+			rulePath := path.Child("rules").Index(j)
+			if !domainLists[rule.DomainList] {
+				errs = append(errs, field.NotFound(rulePath.Child("domainList"), rule.DomainList))
+			}
+			if rule.Action != "Block" && (rule.BlockOverrideDomain != nil || rule.BlockOverrideTTL != nil) {
+				errs = append(errs, field.Forbidden(rulePath, "block overrides only apply to Block rules"))
+			}
+			if rule.Action == "Block" && rule.BlockResponse == "Override" && rule.BlockOverrideDomain == nil {
+				errs = append(errs, field.Required(rulePath.Child("blockOverrideDomain"), "Override block responses need a domain"))
+			}
+		}
+	}
+	return errs
+}
+
+// validateDestinationARN checks that query logs are sent to a CloudWatch Logs log group, S3 bucket or Kinesis Data Firehose delivery stream
+func validateDestinationARN(path *field.Path, destinationARN string) field.ErrorList {
+	parsed, err := arn.Parse(destinationARN)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, destinationARN, err.Error())}
+	}
+	switch parsed.Service {
+	case "logs", "s3", "firehose":
+		return nil
+	default:
+		return field.ErrorList{field.NotSupported(path.Child("service"), parsed.Service, []string{"logs", "s3", "firehose"})}
+	}
+}
+
+func validateEndpoint(path *field.Path, endpoint *ResolverEndpoint) field.ErrorList {
+	if endpoint == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	subnets := map[string]bool{}
+	for i, subnetID := range endpoint.SubnetIDs {
+		if subnets[subnetID] {
+			errs = append(errs, field.Duplicate(path.Child("subnetIDs").Index(i), subnetID))
+		}
+		subnets[subnetID] = true
+	}
+	ips := map[string]bool{}
+	for i, ipAddress := range endpoint.IPAddresses {
+		ipPath := path.Child("ipAddresses").Index(i)
+		if !subnets[ipAddress.SubnetID] {
+			errs = append(errs, field.Invalid(ipPath.Child("subnetID"), ipAddress.SubnetID, "must be one of the subnetIDs of the endpoint"))
+		}
+		if ip := net.ParseIP(ipAddress.IP); ip == nil || ip.To4() == nil {
+			errs = append(errs, field.Invalid(ipPath.Child("ip"), ipAddress.IP, "must be an IPv4 address"))
+		}
+		if ips[ipAddress.IP] {
+			errs = append(errs, field.Duplicate(ipPath.Child("ip"), ipAddress.IP))
+		}
+		ips[ipAddress.IP] = true
+	}
+	return errs
+}
+
+// validateEndpointUpdate rejects changes to the security groups of an endpoint, since Route 53 Resolver can not update them
+func validateEndpointUpdate(path *field.Path, old *ResolverEndpoint, endpoint *ResolverEndpoint) field.ErrorList {
+	if old == nil || endpoint == nil {
+		return nil
+	}
+	if strings.Join(old.SecurityGroupIDs, ",") != strings.Join(endpoint.SecurityGroupIDs, ",") {
+		return field.ErrorList{field.Forbidden(path.Child("securityGroupIDs"), "security groups of an endpoint can not be changed, remove the endpoint and add it again")}
+	}
+	return nil
+}
+
+// normalizeDomain lower cases a domain name and removes its trailing dot
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDefault(t *testing.T) {
+	resolver := validResolver("default")
+	resolver.Spec.ForwardingRules[0].DomainName = "Corp.Example.COM."
+	resolver.Spec.ForwardingRules[0].RuleType = ""
+	resolver.Spec.ForwardingRules[0].TargetIPs[0].Port = nil
+	resolver.Spec.FirewallRuleGroups[0].Rules[0].BlockResponse = "Override"
+	resolver.Spec.FirewallRuleGroups[0].Rules[0].BlockOverrideDomain = stringPtr("blocked.example.com")
+	resolver.Spec.FirewallRuleGroups[0].Rules = append(resolver.Spec.FirewallRuleGroups[0].Rules,
+		FirewallRule{Name: "alert", DomainList: "bad", Action: "Alert", BlockResponse: "NXDomain"})
+
+	resolver.Default()
+
+	rule := resolver.Spec.ForwardingRules[0]
+	if rule.DomainName != "corp.example.com" {
+		t.Errorf("domain name was not normalized, got %q", rule.DomainName)
+	}
+	if rule.RuleType != "Forward" {
+		t.Errorf("rule type was not defaulted to Forward, got %q", rule.RuleType)
+	}
+	if rule.TargetIPs[0].Port == nil || *rule.TargetIPs[0].Port != 53 {
+		t.Errorf("target port was not defaulted to 53, got %v", rule.TargetIPs[0].Port)
+	}
+	block := resolver.Spec.FirewallRuleGroups[0].Rules[0]
+	if block.BlockOverrideTTL == nil || *block.BlockOverrideTTL != 300 {
+		t.Errorf("block override ttl was not defaulted to 300, got %v", block.BlockOverrideTTL)
+	}
+	if alert := resolver.Spec.FirewallRuleGroups[0].Rules[1]; alert.BlockResponse != "" {
+		t.Errorf("block response of an Alert rule was not cleared, got %q", alert.BlockResponse)
+	}
+	if err := resolver.ValidateCreate(); err != nil {
+		t.Errorf("defaulted resolver is invalid: %v", err)
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(*Resolver)
+		field  string
+	}{
+		{
+			name:   "bad query logging arn",
+			mutate: func(r *Resolver) { r.Spec.QueryLogging = "arn:aws:logs:us-east-1" },
+			field:  "spec.queryLogging",
+		},
+		{
+			name: "query logging and query log config",
+			mutate: func(r *Resolver) {
+				r.Spec.QueryLogging = "arn:aws:logs:us-east-1:123456789012:log-group:dns"
+				r.Spec.QueryLogConfig = &QueryLogConfig{DestinationARN: "arn:aws:logs:us-east-1:123456789012:log-group:dns"}
+			},
+			field: "spec.queryLogging",
+		},
+		{
+			name:   "query log config for an unsupported service",
+			mutate: func(r *Resolver) { r.Spec.QueryLogging = "arn:aws:sqs:us-east-1:123456789012:dns" },
+			field:  "spec.queryLogging.service",
+		},
+		{
+			name:   "forward rule without an outbound endpoint",
+			mutate: func(r *Resolver) { r.Spec.OutboundEndpoint = nil },
+			field:  "spec.outboundEndpoint",
+		},
+		{
+			name:   "forward rule without target ips",
+			mutate: func(r *Resolver) { r.Spec.ForwardingRules[0].TargetIPs = nil },
+			field:  "spec.forwardingRules[0].targetIPs",
+		},
+		{
+			name:   "firewall rule with a missing domain list",
+			mutate: func(r *Resolver) { r.Spec.FirewallRuleGroups[0].Rules[0].DomainList = "missing" },
+			field:  "spec.firewallRuleGroups[0].rules[0].domainList",
+		},
+		{
+			name: "duplicate firewall rule group priorities",
+			mutate: func(r *Resolver) {
+				group := r.Spec.FirewallRuleGroups[0]
+				group.Name = "other"
+				r.Spec.FirewallRuleGroups = append(r.Spec.FirewallRuleGroups, group)
+			},
+			field: "spec.firewallRuleGroups[1].priority",
+		},
+		{
+			name: "pinned ip address outside the subnets of the endpoint",
+			mutate: func(r *Resolver) {
+				r.Spec.OutboundEndpoint.IPAddresses = []EndpointIPAddress{{SubnetID: "subnet-other", IP: "10.0.0.10"}}
+			},
+			field: "spec.outboundEndpoint.ipAddresses[0].subnetID",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver := validResolver(strings.ReplaceAll(tc.name, " ", "-"))
+			tc.mutate(resolver)
+			resolver.Default()
+			expectInvalid(t, resolver.ValidateCreate(), tc.field)
+
+			if k8sClient == nil {
+				return
+			}
+			resolver = validResolver(strings.ReplaceAll(tc.name, " ", "-"))
+			tc.mutate(resolver)
+			expectInvalid(t, k8sClient.Create(context.Background(), resolver), tc.field)
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	old := validResolver("update")
+	old.Default()
+
+	resolver := old.DeepCopy()
+	resolver.Spec.OutboundEndpoint.SubnetIDs = append(resolver.Spec.OutboundEndpoint.SubnetIDs, "subnet-c")
+	if err := resolver.ValidateUpdate(old); err != nil {
+		t.Errorf("adding a subnet was rejected: %v", err)
+	}
+	resolver = old.DeepCopy()
+	resolver.Spec.OutboundEndpoint.SecurityGroupIDs = []string{"sg-other"}
+	expectInvalid(t, resolver.ValidateUpdate(old), "spec.outboundEndpoint.securityGroupIDs")
+
+	if k8sClient == nil {
+		return
+	}
+	ctx := context.Background()
+	created := validResolver("update")
+	if err := k8sClient.Create(ctx, created); err != nil {
+		t.Fatalf("unable to create resolver: %v", err)
+	}
+	defer func() { _ = k8sClient.Delete(ctx, created) }()
+	created.Spec.OutboundEndpoint.SubnetIDs = append(created.Spec.OutboundEndpoint.SubnetIDs, "subnet-c")
+	if err := k8sClient.Update(ctx, created); err != nil {
+		t.Fatalf("adding a subnet was rejected: %v", err)
+	}
+	created.Spec.OutboundEndpoint.SecurityGroupIDs = []string{"sg-other"}
+	expectInvalid(t, k8sClient.Update(ctx, created), "spec.outboundEndpoint.securityGroupIDs")
+}
+
+func TestWebhookDefaultsOnCreate(t *testing.T) {
+	if k8sClient == nil {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+	ctx := context.Background()
+	resolver := validResolver("defaults")
+	resolver.Spec.ForwardingRules[0].DomainName = "Corp.Example.COM."
+	if err := k8sClient.Create(ctx, resolver); err != nil {
+		t.Fatalf("unable to create resolver: %v", err)
+	}
+	defer func() { _ = k8sClient.Delete(ctx, resolver) }()
+	if domain := resolver.Spec.ForwardingRules[0].DomainName; domain != "corp.example.com" {
+		t.Errorf("domain name was not normalized by the mutating webhook, got %q", domain)
+	}
+}
+
+// validResolver returns a Resolver using every part of the spec that passes validation
+func validResolver(name string) *Resolver {
+	return &Resolver{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: ResolverSpec{
+			OutboundEndpoint: &ResolverEndpoint{
+				SubnetIDs:        []string{"subnet-a", "subnet-b"},
+				SecurityGroupIDs: []string{"sg-a"},
+			},
+			ForwardingRules: []ForwardingRule{{
+				Name:       "corp",
+				DomainName: "corp.example.com",
+				RuleType:   "Forward",
+				TargetIPs:  []TargetAddress{{IP: "10.0.0.2"}},
+			}},
+			FirewallDomainLists: []FirewallDomainList{{
+				Name:    "bad",
+				Domains: []string{"bad.example.com"},
+			}},
+			FirewallRuleGroups: []FirewallRuleGroup{{
+				Name:     "block",
+				Priority: 101,
+				Rules:    []FirewallRule{{Name: "bad", DomainList: "bad", Action: "Block"}},
+			}},
+		},
+	}
+}
+
+// expectInvalid fails the test unless err is an Invalid error for the given field
+func expectInvalid(t *testing.T, err error, field string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected %s to be rejected", field)
+	}
+	if !apierrors.IsInvalid(err) && !apierrors.IsForbidden(err) {
+		t.Fatalf("expected an invalid error for %s, got %v", field, err)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("%s:", field)) {
+		t.Fatalf("expected an error for %s, got %v", field, err)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
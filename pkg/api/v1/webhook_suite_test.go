@@ -0,0 +1,167 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// k8sClient talks to the envtest apiserver, which sends Resolvers through the webhooks served by the test manager.
+// It is nil when KUBEBUILDER_ASSETS is not set, e.g. when the tests are not run through `make test`.
+var k8sClient client.Client
+
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		os.Exit(m.Run())
+	}
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "..", "chart", "k53", "files")},
+		ErrorIfCRDPathMissing: true,
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			MutatingWebhooks:   []*admissionv1.MutatingWebhookConfiguration{mutatingWebhook()},
+			ValidatingWebhooks: []*admissionv1.ValidatingWebhookConfiguration{validatingWebhook()},
+		},
+	}
+	if _, err := testEnv.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to start envtest: %v\n", err)
+		os.Exit(1)
+	}
+	code, err := runWithWebhooks(m, testEnv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		code = 1
+	}
+	if err := testEnv.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to stop envtest: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+// runWithWebhooks serves the webhooks of Resolvers on the address envtest configured them with and runs the tests
+func runWithWebhooks(m *testing.M, testEnv *envtest.Environment) (int, error) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		return 0, fmt.Errorf("unable to add the v1 api to the scheme: %w", err)
+	}
+	options := testEnv.WebhookInstallOptions
+	mgr, err := ctrl.NewManager(testEnv.Config, ctrl.Options{
+		Scheme:             scheme,
+		Host:               options.LocalServingHost,
+		Port:               options.LocalServingPort,
+		CertDir:            options.LocalServingCertDir,
+		LeaderElection:     false,
+		MetricsBindAddress: "0",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to create manager: %w", err)
+	}
+	if err := (&Resolver{}).SetupWebhookWithManager(mgr); err != nil {
+		return 0, fmt.Errorf("unable to create webhook: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to start manager: %v\n", err)
+		}
+	}()
+	if err := waitForWebhookServer(options.LocalServingHost, options.LocalServingPort); err != nil {
+		return 0, err
+	}
+	k8sClient, err = client.New(testEnv.Config, client.Options{Scheme: scheme})
+	if err != nil {
+		return 0, fmt.Errorf("unable to create client: %w", err)
+	}
+	return m.Run(), nil
+}
+
+func waitForWebhookServer(host string, port int) error {
+	address := net.JoinHostPort(host, fmt.Sprint(port))
+	dialer := &net.Dialer{Timeout: time.Second}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("webhook server did not start on %s: %w", address, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// mutatingWebhook and validatingWebhook mirror the Resolver webhooks of the chart, envtest points them at the test manager
+func mutatingWebhook() *admissionv1.MutatingWebhookConfiguration {
+	sideEffects := admissionv1.SideEffectClassNone
+	failurePolicy := admissionv1.Fail
+	path := "/mutate-src-bwag-me-v1-resolver"
+	config := &admissionv1.MutatingWebhookConfiguration{
+		Webhooks: []admissionv1.MutatingWebhook{{
+			Name:                    "mresolver.src.bwag.me",
+			AdmissionReviewVersions: []string{"v1"},
+			SideEffects:             &sideEffects,
+			FailurePolicy:           &failurePolicy,
+			ClientConfig:            admissionv1.WebhookClientConfig{Service: &admissionv1.ServiceReference{Path: &path}},
+			Rules:                   resolverRules(),
+		}},
+	}
+	config.Name = "k53"
+	return config
+}
+
+func validatingWebhook() *admissionv1.ValidatingWebhookConfiguration {
+	sideEffects := admissionv1.SideEffectClassNone
+	failurePolicy := admissionv1.Fail
+	path := "/validate-src-bwag-me-v1-resolver"
+	config := &admissionv1.ValidatingWebhookConfiguration{
+		Webhooks: []admissionv1.ValidatingWebhook{{
+			Name:                    "vresolver.src.bwag.me",
+			AdmissionReviewVersions: []string{"v1"},
+			SideEffects:             &sideEffects,
+			FailurePolicy:           &failurePolicy,
+			ClientConfig:            admissionv1.WebhookClientConfig{Service: &admissionv1.ServiceReference{Path: &path}},
+			Rules:                   resolverRules(),
+		}},
+	}
+	config.Name = "k53"
+	return config
+}
+
+func resolverRules() []admissionv1.RuleWithOperations {
+	return []admissionv1.RuleWithOperations{{
+		Operations: []admissionv1.OperationType{admissionv1.Create, admissionv1.Update},
+		Rule: admissionv1.Rule{
+			APIGroups:   []string{GroupVersion.Group},
+			APIVersions: []string{GroupVersion.Version},
+			Resources:   []string{"resolvers"},
+		},
+	}}
+}
@@ -22,15 +22,239 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AssumeRole) DeepCopyInto(out *AssumeRole) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AssumeRole.
+func (in *AssumeRole) DeepCopy() *AssumeRole {
+	if in == nil {
+		return nil
+	}
+	out := new(AssumeRole)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointIPAddress) DeepCopyInto(out *EndpointIPAddress) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointIPAddress.
+func (in *EndpointIPAddress) DeepCopy() *EndpointIPAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointIPAddress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallDomainList) DeepCopyInto(out *FirewallDomainList) {
+	*out = *in
+	if in.Domains != nil {
+		in, out := &in.Domains, &out.Domains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallDomainList.
+func (in *FirewallDomainList) DeepCopy() *FirewallDomainList {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallDomainList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallDomainListStatus) DeepCopyInto(out *FirewallDomainListStatus) {
+	*out = *in
+	if in.DomainListID != nil {
+		in, out := &in.DomainListID, &out.DomainListID
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallDomainListStatus.
+func (in *FirewallDomainListStatus) DeepCopy() *FirewallDomainListStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallDomainListStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallRule) DeepCopyInto(out *FirewallRule) {
+	*out = *in
+	if in.BlockOverrideDomain != nil {
+		in, out := &in.BlockOverrideDomain, &out.BlockOverrideDomain
+		*out = new(string)
+		**out = **in
+	}
+	if in.BlockOverrideTTL != nil {
+		in, out := &in.BlockOverrideTTL, &out.BlockOverrideTTL
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallRule.
+func (in *FirewallRule) DeepCopy() *FirewallRule {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallRuleGroup) DeepCopyInto(out *FirewallRuleGroup) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]FirewallRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallRuleGroup.
+func (in *FirewallRuleGroup) DeepCopy() *FirewallRuleGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallRuleGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallRuleGroupStatus) DeepCopyInto(out *FirewallRuleGroupStatus) {
+	*out = *in
+	if in.RuleGroupID != nil {
+		in, out := &in.RuleGroupID, &out.RuleGroupID
+		*out = new(string)
+		**out = **in
+	}
+	if in.AssociationID != nil {
+		in, out := &in.AssociationID, &out.AssociationID
+		*out = new(string)
+		**out = **in
+	}
+	if in.AssociationStatus != nil {
+		in, out := &in.AssociationStatus, &out.AssociationStatus
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallRuleGroupStatus.
+func (in *FirewallRuleGroupStatus) DeepCopy() *FirewallRuleGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallRuleGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ForwardingRule) DeepCopyInto(out *ForwardingRule) {
+	*out = *in
+	if in.TargetIPs != nil {
+		in, out := &in.TargetIPs, &out.TargetIPs
+		*out = make([]TargetAddress, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ForwardingRule.
+func (in *ForwardingRule) DeepCopy() *ForwardingRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ForwardingRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ForwardingRuleStatus) DeepCopyInto(out *ForwardingRuleStatus) {
+	*out = *in
+	if in.RuleID != nil {
+		in, out := &in.RuleID, &out.RuleID
+		*out = new(string)
+		**out = **in
+	}
+	if in.AssociationID != nil {
+		in, out := &in.AssociationID, &out.AssociationID
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ForwardingRuleStatus.
+func (in *ForwardingRuleStatus) DeepCopy() *ForwardingRuleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ForwardingRuleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QueryLogConfig) DeepCopyInto(out *QueryLogConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueryLogConfig.
+func (in *QueryLogConfig) DeepCopy() *QueryLogConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(QueryLogConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Resolver) DeepCopyInto(out *Resolver) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -52,6 +276,36 @@ func (in *Resolver) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResolverEndpoint) DeepCopyInto(out *ResolverEndpoint) {
+	*out = *in
+	if in.SubnetIDs != nil {
+		in, out := &in.SubnetIDs, &out.SubnetIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityGroupIDs != nil {
+		in, out := &in.SecurityGroupIDs, &out.SecurityGroupIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPAddresses != nil {
+		in, out := &in.IPAddresses, &out.IPAddresses
+		*out = make([]EndpointIPAddress, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolverEndpoint.
+func (in *ResolverEndpoint) DeepCopy() *ResolverEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(ResolverEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResolverList) DeepCopyInto(out *ResolverList) {
 	*out = *in
@@ -87,6 +341,47 @@ func (in *ResolverList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResolverSpec) DeepCopyInto(out *ResolverSpec) {
 	*out = *in
+	if in.AssumeRole != nil {
+		in, out := &in.AssumeRole, &out.AssumeRole
+		*out = new(AssumeRole)
+		**out = **in
+	}
+	if in.QueryLogConfig != nil {
+		in, out := &in.QueryLogConfig, &out.QueryLogConfig
+		*out = new(QueryLogConfig)
+		**out = **in
+	}
+	if in.OutboundEndpoint != nil {
+		in, out := &in.OutboundEndpoint, &out.OutboundEndpoint
+		*out = new(ResolverEndpoint)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InboundEndpoint != nil {
+		in, out := &in.InboundEndpoint, &out.InboundEndpoint
+		*out = new(ResolverEndpoint)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ForwardingRules != nil {
+		in, out := &in.ForwardingRules, &out.ForwardingRules
+		*out = make([]ForwardingRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FirewallDomainLists != nil {
+		in, out := &in.FirewallDomainLists, &out.FirewallDomainLists
+		*out = make([]FirewallDomainList, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FirewallRuleGroups != nil {
+		in, out := &in.FirewallRuleGroups, &out.FirewallRuleGroups
+		*out = make([]FirewallRuleGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolverSpec.
@@ -102,11 +397,59 @@ func (in *ResolverSpec) DeepCopy() *ResolverSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResolverStatus) DeepCopyInto(out *ResolverStatus) {
 	*out = *in
-	if in.State != nil {
-		in, out := &in.State, &out.State
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.QueryLogConfigID != nil {
+		in, out := &in.QueryLogConfigID, &out.QueryLogConfigID
+		*out = new(string)
+		**out = **in
+	}
+	if in.QueryLogConfigAssociationID != nil {
+		in, out := &in.QueryLogConfigAssociationID, &out.QueryLogConfigAssociationID
 		*out = new(string)
 		**out = **in
 	}
+	if in.OutboundEndpointID != nil {
+		in, out := &in.OutboundEndpointID, &out.OutboundEndpointID
+		*out = new(string)
+		**out = **in
+	}
+	if in.InboundEndpointID != nil {
+		in, out := &in.InboundEndpointID, &out.InboundEndpointID
+		*out = new(string)
+		**out = **in
+	}
+	if in.InboundEndpointIPs != nil {
+		in, out := &in.InboundEndpointIPs, &out.InboundEndpointIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ForwardingRules != nil {
+		in, out := &in.ForwardingRules, &out.ForwardingRules
+		*out = make([]ForwardingRuleStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FirewallDomainLists != nil {
+		in, out := &in.FirewallDomainLists, &out.FirewallDomainLists
+		*out = make([]FirewallDomainListStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FirewallRuleGroups != nil {
+		in, out := &in.FirewallRuleGroups, &out.FirewallRuleGroups
+		*out = make([]FirewallRuleGroupStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolverStatus.
@@ -118,3 +461,23 @@ func (in *ResolverStatus) DeepCopy() *ResolverStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetAddress) DeepCopyInto(out *TargetAddress) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetAddress.
+func (in *TargetAddress) DeepCopy() *TargetAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetAddress)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// AnnotationV1Resolver holds the v1 spec and status of a Resolver read as v1alpha1, so the fields v1alpha1 can not
+// represent survive a round trip through v1alpha1
+const AnnotationV1Resolver = "src.bwag.me/v1-resolver"
+
+// v1Resolver is the part of a v1 Resolver stored in AnnotationV1Resolver
+type v1Resolver struct {
+	Spec   srcv1.ResolverSpec   `json:"spec"`
+	Status srcv1.ResolverStatus `json:"status"`
+}
+
+var _ conversion.Convertible = &Resolver{}
+
+// ConvertTo converts a v1alpha1 Resolver to v1, restoring the v1 fields kept in AnnotationV1Resolver.
+// The query log destination of v1alpha1 takes precedence when it was changed through v1alpha1.
+func (r *Resolver) ConvertTo(hub conversion.Hub) error {
+	dst := hub.(*srcv1.Resolver)
+	dst.ObjectMeta = *r.ObjectMeta.DeepCopy()
+	var restored v1Resolver
+	if data, ok := dst.Annotations[AnnotationV1Resolver]; ok {
+		if err := json.Unmarshal([]byte(data), &restored); err != nil {
+			return fmt.Errorf("unable to restore v1 fields of resolver %s/%s: %w", r.Namespace, r.Name, err)
+		}
+		delete(dst.Annotations, AnnotationV1Resolver)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+	dst.Spec = restored.Spec
+	dst.Status = restored.Status
+	if r.Spec.QueryLogConfig != queryLogDestination(&dst.Spec) {
+		dst.Spec.QueryLogging = r.Spec.QueryLogConfig
+		dst.Spec.QueryLogConfig = nil
+	}
+	// a state written through v1alpha1 without the v1 status becomes the Ready condition it is derived from
+	if r.Status.State != nil && meta.FindStatusCondition(dst.Status.Conditions, srcv1.ConditionTypeReady) == nil {
+		ready := metav1.Condition{Type: srcv1.ConditionTypeReady, Status: metav1.ConditionFalse, Reason: "Failed"}
+		if *r.Status.State == "Synchronized" {
+			ready.Status = metav1.ConditionTrue
+			ready.Reason = "Synchronized"
+		}
+		meta.SetStatusCondition(&dst.Status.Conditions, ready)
+	}
+	return nil
+}
+
+// ConvertFrom converts a v1 Resolver to v1alpha1, keeping the fields v1alpha1 can not represent in AnnotationV1Resolver
+func (r *Resolver) ConvertFrom(hub conversion.Hub) error {
+	src := hub.(*srcv1.Resolver)
+	r.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	r.Spec.QueryLogConfig = queryLogDestination(&src.Spec)
+	r.Status.State = nil
+	if ready := meta.FindStatusCondition(src.Status.Conditions, srcv1.ConditionTypeReady); ready != nil {
+		state := "Failed"
+		if ready.Status == "True" {
+			state = "Synchronized"
+		}
+		r.Status.State = &state
+	}
+	data, err := json.Marshal(v1Resolver{Spec: src.Spec, Status: src.Status})
+	if err != nil {
+		return fmt.Errorf("unable to keep v1 fields of resolver %s/%s: %w", src.Namespace, src.Name, err)
+	}
+	if r.Annotations == nil {
+		r.Annotations = map[string]string{}
+	}
+	r.Annotations[AnnotationV1Resolver] = string(data)
+	return nil
+}
+
+// queryLogDestination returns the destination ARN of the effective query log config of a v1 spec
+func queryLogDestination(spec *srcv1.ResolverSpec) string {
+	if config := spec.EffectiveQueryLogConfig(); config != nil {
+		return config.DestinationARN
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	fuzz "github.com/google/gofuzz"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+const fuzzIterations = 1000
+
+func TestConversionRoundTripFromHub(t *testing.T) {
+	f := conversionFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		original := &srcv1.Resolver{}
+		f.Fuzz(original)
+
+		spoke := &Resolver{}
+		if err := spoke.ConvertFrom(original.DeepCopy()); err != nil {
+			t.Fatalf("unable to convert v1 to v1alpha1: %v", err)
+		}
+		hub := &srcv1.Resolver{}
+		if err := spoke.ConvertTo(hub); err != nil {
+			t.Fatalf("unable to convert v1alpha1 to v1: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(original, hub) {
+			t.Fatalf("v1 -> v1alpha1 -> v1 round trip changed the resolver:\n%s", diff.ObjectReflectDiff(original, hub))
+		}
+	}
+}
+
+func TestConversionRoundTripFromSpoke(t *testing.T) {
+	f := conversionFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		original := &Resolver{}
+		f.Fuzz(original)
+
+		hub := &srcv1.Resolver{}
+		if err := original.DeepCopy().ConvertTo(hub); err != nil {
+			t.Fatalf("unable to convert v1alpha1 to v1: %v", err)
+		}
+		spoke := &Resolver{}
+		if err := spoke.ConvertFrom(hub); err != nil {
+			t.Fatalf("unable to convert v1 to v1alpha1: %v", err)
+		}
+		// the annotation keeping the v1 fields is added by design
+		delete(spoke.Annotations, AnnotationV1Resolver)
+		if !equality.Semantic.DeepEqual(original, spoke) {
+			t.Fatalf("v1alpha1 -> v1 -> v1alpha1 round trip changed the resolver:\n%s", diff.ObjectReflectDiff(original, spoke))
+		}
+	}
+}
+
+func conversionFuzzer(t *testing.T) *fuzz.Fuzzer {
+	seed := time.Now().UnixNano()
+	t.Logf("fuzzing with seed %d", seed)
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	funcs := fuzzer.MergeFuzzerFuncs(metafuzzer.Funcs, resolverFuzzerFuncs)
+	return fuzzer.FuzzerFor(funcs, rand.NewSource(seed), runtimeserializer.NewCodecFactory(scheme))
+}
+
+// resolverFuzzerFuncs keep fuzzed Resolvers within what the apiserver stores: times in seconds, since the v1 fields
+// are kept as JSON, and the v1alpha1 states the controller writes
+func resolverFuzzerFuncs(_ runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(j *metav1.Time, c fuzz.Continue) {
+			*j = metav1.Unix(int64(c.Rand.Uint32()), 0)
+		},
+		func(j *ResolverStatus, c fuzz.Continue) {
+			switch c.Intn(3) {
+			case 0:
+				j.State = nil
+			case 1:
+				state := "Synchronized"
+				j.State = &state
+			default:
+				state := "Failed"
+				j.State = &state
+			}
+		},
+	}
+}
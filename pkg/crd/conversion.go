@@ -0,0 +1,94 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package crd keeps the k53 CRDs working across API versions: it points the conversion of Resolvers at the webhook
+// server and migrates stored Resolvers to the storage version.
+package crd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ResolverCRDName is the name of the Resolver CRD
+const ResolverCRDName = "resolvers.src.bwag.me"
+
+// WebhookService is the Service fronting the webhook server
+type WebhookService struct {
+	Name      string
+	Namespace string
+	Port      int32
+}
+
+// EnsureConversionWebhook configures the Resolver CRD to convert between versions through the /convert endpoint of
+// the webhook server. The chart renders the CRD with the release's service and CA already, the controller keeps them
+// in sync with its flags and the ca.crt next to the serving certificate, e.g. when the CRD was installed without the
+// chart. The CRD is only patched when it differs.
+func EnsureConversionWebhook(ctx context.Context, c client.Client, service WebhookService, certDir string) error {
+	caBundle, err := os.ReadFile(filepath.Join(certDir, "ca.crt"))
+	if err != nil {
+		return fmt.Errorf("unable to read the ca bundle of the webhook server: %w", err)
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := c.Get(ctx, client.ObjectKey{Name: ResolverCRDName}, crd); err != nil {
+		return fmt.Errorf("unable to get crd %s: %w", ResolverCRDName, err)
+	}
+	path := "/convert"
+	conversion := &apiextensionsv1.CustomResourceConversion{
+		Strategy: apiextensionsv1.WebhookConverter,
+		Webhook: &apiextensionsv1.WebhookConversion{
+			ClientConfig: &apiextensionsv1.WebhookClientConfig{
+				Service: &apiextensionsv1.ServiceReference{
+					Name:      service.Name,
+					Namespace: service.Namespace,
+					Path:      &path,
+					Port:      &service.Port,
+				},
+				CABundle: caBundle,
+			},
+			ConversionReviewVersions: []string{"v1"},
+		},
+	}
+	if equality.Semantic.DeepEqual(crd.Spec.Conversion, conversion) {
+		return nil
+	}
+	patch := client.MergeFrom(crd.DeepCopy())
+	crd.Spec.Conversion = conversion
+	if err := c.Patch(ctx, crd, patch); err != nil {
+		return fmt.Errorf("unable to configure conversion webhook of crd %s: %w", ResolverCRDName, err)
+	}
+	return nil
+}
+
+// KeepConversionWebhook runs EnsureConversionWebhook every interval until the context is done, so that the CA bundle
+// follows a rotated serving certificate and the configuration is restored after the CRD is reinstalled
+func KeepConversionWebhook(ctx context.Context, c client.Client, service WebhookService, certDir string, interval time.Duration) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := EnsureConversionWebhook(ctx, c, service, certDir); err != nil {
+			log.FromContext(ctx).Error(err, "unable to configure conversion webhook")
+		}
+	}, interval)
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// MigrateStorage rewrites every Resolver in the storage version and then drops all other versions from the CRD's
+// storedVersions, so that older versions can eventually be removed from the CRD.
+// Resolvers are read through reader as unstructured objects, so that fields unknown to this version of the controller
+// are written back unchanged. Nothing is migrated once storedVersions only holds the storage version, nor while the
+// CRD does not convert through the webhook, since the apiserver would only rewrite the apiVersion of older objects.
+func MigrateStorage(ctx context.Context, reader client.Reader, c client.Client) error {
+	logger := log.FromContext(ctx)
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := reader.Get(ctx, client.ObjectKey{Name: ResolverCRDName}, crd); err != nil {
+		return fmt.Errorf("unable to get crd %s: %w", ResolverCRDName, err)
+	}
+	var storageVersion string
+	for _, version := range crd.Spec.Versions {
+		if version.Storage {
+			storageVersion = version.Name
+		}
+	}
+	if len(crd.Status.StoredVersions) == 1 && crd.Status.StoredVersions[0] == storageVersion {
+		return nil
+	}
+	if crd.Spec.Conversion == nil || crd.Spec.Conversion.Strategy != apiextensionsv1.WebhookConverter {
+		return fmt.Errorf("crd %s does not convert through the webhook", ResolverCRDName)
+	}
+
+	resolvers := &unstructured.UnstructuredList{}
+	resolvers.SetGroupVersionKind(srcv1.GroupVersion.WithKind("ResolverList"))
+	if err := reader.List(ctx, resolvers); err != nil {
+		return fmt.Errorf("unable to list resolvers: %w", err)
+	}
+	for i := range resolvers.Items {
+		resolver := &resolvers.Items[i]
+		// an update without changes still rewrites the object in the storage version
+		if err := c.Update(ctx, resolver); err != nil {
+			return fmt.Errorf("unable to migrate resolver %s/%s: %w", resolver.GetNamespace(), resolver.GetName(), err)
+		}
+	}
+
+	patch := client.MergeFrom(crd.DeepCopy())
+	crd.Status.StoredVersions = []string{storageVersion}
+	if err := c.Status().Patch(ctx, crd, patch); err != nil {
+		return fmt.Errorf("unable to update stored versions of crd %s: %w", ResolverCRDName, err)
+	}
+	logger.Info("migrated resolvers to the storage version", "version", storageVersion, "resolvers", len(resolvers.Items))
+	return nil
+}
+
+// RunStorageMigration runs MigrateStorage once the webhook server is started, since the apiserver converts the
+// stored Resolvers through it, and retries every interval until the migration succeeds or the context is done
+func RunStorageMigration(ctx context.Context, reader client.Reader, c client.Client, webhookStarted func() error, interval time.Duration) error {
+	logger := log.FromContext(ctx)
+	_ = wait.PollImmediateUntilWithContext(ctx, interval, func(ctx context.Context) (bool, error) {
+		if err := webhookStarted(); err != nil {
+			logger.V(1).Info("waiting for the webhook server before migrating stored resolvers", "reason", err.Error())
+			return false, nil
+		}
+		if err := MigrateStorage(ctx, reader, c); err != nil {
+			logger.Error(err, "unable to migrate stored resolvers, retrying", "interval", interval)
+			return false, nil
+		}
+		return true, nil
+	})
+	return nil
+}
@@ -0,0 +1,175 @@
+package dnsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	v1 "k8s.io/api/core/v1"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	k53session "github.com/bwagner5/k53/pkg/session"
+	"github.com/bwagner5/k53/pkg/zone"
+)
+
+const (
+	// Path is the path the injector is served at by the webhook server
+	Path = "/mutate-v1-pod-dnsconfig"
+	// NamespaceLabel opts the pods of a namespace into DNS injection when set to "enabled"
+	NamespaceLabel = "k53.bwag.me/dns-injection"
+	// OptOutAnnotation skips DNS injection for a pod when set to "disabled"
+	OptOutAnnotation = "k53.bwag.me/dns-injection"
+
+	// maxNameservers and maxSearches are the limits the apiserver validates the dnsConfig of pods against
+	maxNameservers = 3
+	maxSearches    = 6
+)
+
+// Options configure the name layout the injected search domains are derived from
+type Options struct {
+	ZoneName            string
+	ClusterName         string
+	ServiceNameTemplate string
+}
+
+// Injector is a mutating Pod webhook pointing pods at the VPC resolver instead of kube-dns, with search domains
+// matching the k53 zone layout
+type Injector struct {
+	nameserver string
+	opts       Options
+	decoder    *admission.Decoder
+}
+
+var _ admission.Handler = &Injector{}
+
+// New looks up the VPC resolver of the cluster's VPC, the second address of the VPC's primary CIDR block. It fails if
+// the service name template does not allow resolving services through search domains.
+func New(ctx context.Context, sess *session.Session, vpc *k53session.VPC, opts Options) (*Injector, error) {
+	if _, err := zone.SearchDomains(opts.ServiceNameTemplate, opts.ZoneName, opts.ClusterName, "default"); err != nil {
+		return nil, fmt.Errorf("unable to derive search domains: %w", err)
+	}
+	vpcID, err := vpc.ID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get vpc id: %w", err)
+	}
+	out, err := ec2.New(sess).DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{VpcIds: []*string{aws.String(vpcID)}})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe vpc %s: %w", vpcID, err)
+	}
+	if len(out.Vpcs) == 0 {
+		return nil, fmt.Errorf("vpc %s not found", vpcID)
+	}
+	nameserver, err := vpcResolverIP(aws.StringValue(out.Vpcs[0].CidrBlock))
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("Injecting VPC resolver %s of vpc %s into pods of namespaces labeled %s=enabled", nameserver, vpcID, NamespaceLabel)
+	return &Injector{
+		nameserver: nameserver,
+		opts:       opts,
+	}, nil
+}
+
+// InjectDecoder is called by the webhook server when the injector is registered
+func (i *Injector) InjectDecoder(decoder *admission.Decoder) error {
+	i.decoder = decoder
+	return nil
+}
+
+// Handle sets dnsPolicy None and a dnsConfig resolving through the VPC resolver. Pods that already set dnsPolicy None
+// manage their own DNS and are left alone, as are pods opting out with the k53.bwag.me/dns-injection annotation.
+func (i *Injector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &v1.Pod{}
+	if err := i.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if pod.Spec.DNSPolicy == v1.DNSNone {
+		return admission.Allowed("pod manages its own dns config")
+	}
+	if pod.Annotations[OptOutAnnotation] == "disabled" {
+		return admission.Allowed("pod opted out of dns injection")
+	}
+	searches, err := zone.SearchDomains(i.opts.ServiceNameTemplate, i.opts.ZoneName, i.opts.ClusterName, req.Namespace)
+	if err != nil {
+		klog.Errorf("not injecting dns config into pod %s/%s: %v", req.Namespace, pod.Name, err)
+		return admission.Allowed("no search domains for the namespace")
+	}
+	pod.Spec.DNSPolicy = v1.DNSNone
+	pod.Spec.DNSConfig = i.dnsConfig(searches, pod.Spec.DNSConfig)
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// dnsConfig returns the dnsConfig of a pod resolving through the VPC resolver and the search domains first, followed by
+// the nameservers, search domains and options the pod sets itself
+func (i *Injector) dnsConfig(searches []string, existing *v1.PodDNSConfig) *v1.PodDNSConfig {
+	ndots := "2"
+	config := &v1.PodDNSConfig{
+		Nameservers: []string{i.nameserver},
+		Searches:    searches,
+		// <name>.<namespace> has to go through the search domains before being resolved as is
+		Options: []v1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+	}
+	if existing == nil {
+		return config
+	}
+	config.Nameservers = merge(config.Nameservers, existing.Nameservers, maxNameservers)
+	config.Searches = merge(config.Searches, existing.Searches, maxSearches)
+	for _, option := range existing.Options {
+		if option.Name == "ndots" {
+			config.Options = nil
+		}
+	}
+	config.Options = append(config.Options, existing.Options...)
+	return config
+}
+
+// merge appends the values missing from values to injected, dropping the values beyond max
+func merge(injected []string, values []string, max int) []string {
+	merged := append([]string{}, injected...)
+	for _, value := range values {
+		if contains(merged, value) {
+			continue
+		}
+		if len(merged) == max {
+			klog.Warningf("dropping %q from the dns config of a pod, only %d values are allowed", value, max)
+			continue
+		}
+		merged = append(merged, value)
+	}
+	return merged
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// vpcResolverIP returns the address of the Route 53 VPC resolver, the network address of the VPC CIDR block plus two
+func vpcResolverIP(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse vpc cidr block %q: %w", cidr, err)
+	}
+	ip := network.IP.To4()
+	if ip == nil {
+		return "", fmt.Errorf("vpc cidr block %q is not an ipv4 block", cidr)
+	}
+	resolver := make(net.IP, len(ip))
+	copy(resolver, ip)
+	resolver[3] += 2
+	return resolver.String(), nil
+}
@@ -18,23 +18,41 @@ package resolver
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/aws/smithy-go/ptr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53resolver"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+	k53session "github.com/bwagner5/k53/pkg/session"
 )
 
+// Finalizer is added to Resolvers so the AWS resources they own are cleaned up before they are deleted
+const Finalizer = "src.bwag.me/resolver"
+
 // ResolverReconciler reconciles a DNS object
 type Reconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme          *runtime.Scheme
+	Session         *session.Session
+	Route53Resolver *route53resolver.Route53Resolver
+	// VPC is the cluster's VPC the Resolver's AWS resources are associated with
+	VPC *k53session.VPC
 }
 
 // For more details, check Reconcile and its Result here:
@@ -51,20 +69,188 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	dns.Status.State = ptr.String("Synchronized")
-	if err := r.Status().Update(ctx, &dns); err != nil {
+	if !dns.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&dns, Finalizer) {
+			return ctrl.Result{}, nil
+		}
+		original := dns.DeepCopy()
+		if err := r.cleanup(ctx, &dns); err != nil {
+			log.Error(err, "unable to clean up Resolver")
+			setStatus(&dns, "CleanupFailed", err)
+			if err := r.Status().Patch(ctx, &dns, client.MergeFrom(original)); err != nil {
+				log.Error(err, "unable to update Resolver status")
+			}
+			return ctrl.Result{}, err
+		}
+		patch := client.MergeFromWithOptions(dns.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		controllerutil.RemoveFinalizer(&dns, Finalizer)
+		if err := r.Patch(ctx, &dns, patch); err != nil {
+			log.Error(err, "unable to remove Resolver finalizer")
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		log.Info(fmt.Sprintf(`Cleaned up "%s"`, req.NamespacedName))
+		return ctrl.Result{}, nil
+	}
+	owner, err := r.electResolver(ctx)
+	if err != nil {
+		log.Error(err, "unable to elect the effective Resolver")
+		return ctrl.Result{}, err
+	}
+	if owner != nil && client.ObjectKeyFromObject(owner) != req.NamespacedName {
+		// a Resolver with a finalizer was elected before, the AWS resources it created are cleaned up like on deletion
+		// so they do not conflict with those of the effective Resolver, and losing Resolvers need no finalizer
+		original := dns.DeepCopy()
+		release := controllerutil.ContainsFinalizer(&dns, Finalizer)
+		if release {
+			if err := r.cleanup(ctx, &dns); err != nil {
+				log.Error(err, "unable to clean up Resolver")
+				setStatus(&dns, "CleanupFailed", err)
+				if err := r.Status().Patch(ctx, &dns, client.MergeFrom(original)); err != nil {
+					log.Error(err, "unable to update Resolver status")
+				}
+				return ctrl.Result{}, err
+			}
+		}
+		setConflict(&dns, owner)
+		if err := r.Status().Patch(ctx, &dns, client.MergeFrom(original)); err != nil {
+			log.Error(err, "unable to update Resolver status")
+			return ctrl.Result{}, err
+		}
+		if release {
+			patch := client.MergeFromWithOptions(dns.DeepCopy(), client.MergeFromWithOptimisticLock{})
+			controllerutil.RemoveFinalizer(&dns, Finalizer)
+			if err := r.Patch(ctx, &dns, patch); err != nil {
+				log.Error(err, "unable to remove Resolver finalizer")
+				return ctrl.Result{}, err
+			}
+			log.Info(fmt.Sprintf(`Cleaned up "%s"`, req.NamespacedName))
+		}
+		log.Info(fmt.Sprintf(`Not reconciling "%s", "%s" is the effective Resolver`, req.NamespacedName, client.ObjectKeyFromObject(owner)))
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&dns, Finalizer) {
+		patch := client.MergeFromWithOptions(dns.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		controllerutil.AddFinalizer(&dns, Finalizer)
+		if err := r.Patch(ctx, &dns, patch); err != nil {
+			log.Error(err, "unable to add Resolver finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// the status is patched even if reconciling fails, so the ids of the AWS resources created so far are kept
+	original := dns.DeepCopy()
+	scoped, err := r.forResolver(ctx, &dns)
+	if err != nil {
+		log.Error(err, "unable to reconcile Resolver")
+		setStatus(&dns, "AssumeRoleFailed", err)
+		if err := r.Status().Patch(ctx, &dns, client.MergeFrom(original)); err != nil {
+			log.Error(err, "unable to update Resolver status")
+		}
+		return ctrl.Result{}, err
+	}
+	spec := dns.Spec
+	var errs []error
+	for _, feature := range []struct {
+		conditionType string
+		configured    bool
+		reconcile     func(context.Context, *srcv1.Resolver) error
+	}{
+		{srcv1.ConditionTypeQueryLogging, spec.EffectiveQueryLogConfig() != nil, scoped.reconcileQueryLogConfig},
+		{srcv1.ConditionTypeForwarding, spec.OutboundEndpoint != nil || len(spec.ForwardingRules) > 0, scoped.reconcileForwarding},
+		{srcv1.ConditionTypeFirewall, len(spec.FirewallDomainLists) > 0 || len(spec.FirewallRuleGroups) > 0, scoped.reconcileFirewall},
+		{srcv1.ConditionTypeInboundEndpoint, spec.InboundEndpoint != nil, scoped.reconcileInboundEndpoint},
+	} {
+		err := feature.reconcile(ctx, &dns)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", feature.conditionType, err))
+		}
+		setFeatureCondition(&dns, feature.conditionType, feature.configured, err)
+	}
+	reconcileErr := utilerrors.NewAggregate(errs)
+	clearConflict(&dns)
+	setStatus(&dns, "ReconcileFailed", reconcileErr)
+	if err := r.Status().Patch(ctx, &dns, client.MergeFrom(original)); err != nil {
 		log.Error(err, "unable to update Resolver status")
 		return ctrl.Result{}, err
 	}
+	if reconcileErr != nil {
+		log.Error(reconcileErr, "unable to reconcile Resolver")
+		return ctrl.Result{}, reconcileErr
+	}
 
-	log.Info(fmt.Sprintf(`Reconciled "%s"`, dns.Spec.QueryLogConfig))
+	log.Info(fmt.Sprintf(`Reconciled "%s"`, req.NamespacedName))
 	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
 }
 
+// cleanup deletes the AWS resources of a Resolver being deleted
+func (r *Reconciler) cleanup(ctx context.Context, dns *srcv1.Resolver) error {
+	scoped, err := r.forResolver(ctx, dns)
+	if err != nil {
+		return err
+	}
+	return utilerrors.NewAggregate([]error{
+		scoped.deleteQueryLogConfig(ctx, dns),
+		scoped.deleteForwarding(ctx, dns),
+		scoped.deleteFirewall(ctx, dns),
+		scoped.deleteInboundEndpoint(ctx, dns),
+	})
+}
+
+// forResolver returns the Reconciler managing the AWS resources of a Resolver, which uses the role of the Resolver
+// if it has one. Role sessions are cached, so credentials are only requested from STS when they expire.
+func (r *Reconciler) forResolver(ctx context.Context, dns *srcv1.Resolver) (*Reconciler, error) {
+	if dns.Spec.AssumeRole == nil {
+		meta.RemoveStatusCondition(&dns.Status.Conditions, srcv1.ConditionTypeAssumeRole)
+		return r, nil
+	}
+	sess := k53session.AssumeRole(r.Session, k53session.RoleOptions{
+		RoleARN:     dns.Spec.AssumeRole.RoleARN,
+		ExternalID:  dns.Spec.AssumeRole.ExternalID,
+		SessionName: dns.Spec.AssumeRole.SessionName,
+	})
+	if err := k53session.VerifyCredentials(ctx, sess); err != nil {
+		err = fmt.Errorf("unable to assume role %s: %w", dns.Spec.AssumeRole.RoleARN, err)
+		setAssumeRoleCondition(dns, err)
+		return nil, err
+	}
+	setAssumeRoleCondition(dns, nil)
+	scoped := *r
+	scoped.Route53Resolver = route53resolver.New(sess)
+	return &scoped, nil
+}
+
+// resourceName returns the name of an AWS resource owned by a Resolver, limited to the 64 characters Route 53 Resolver allows
+func resourceName(dns *srcv1.Resolver, suffixes ...string) string {
+	name := strings.Join(append([]string{"k53", dns.Namespace, dns.Name}, suffixes...), "-")
+	name = strings.ReplaceAll(name, ".", "-")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// creatorRequestID returns the idempotency token of a create request for a resource of a Resolver. It is derived from
+// the UID of the Resolver, the kind of the resource and the resource, so a retried create returns the resource made by
+// the first attempt instead of making a duplicate, even when the spec changed in between. Resources replaced because
+// an immutable field changed include that field in resource, so the replacement gets a new token.
+func creatorRequestID(dns *srcv1.Resolver, kind string, resource ...string) *string {
+	id := strings.Join(append([]string{string(dns.UID), kind}, resource...), "/")
+	if len(id) > 255 {
+		sum := sha256.Sum256([]byte(id))
+		id = hex.EncodeToString(sum[:])
+	}
+	return aws.String(id)
+}
+
+func (r *Reconciler) getVPCID(ctx context.Context) (string, error) {
+	return r.VPC.ID(ctx)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&srcv1.Resolver{}).
+		Watches(&source.Kind{Type: &srcv1.Resolver{}}, handler.EnqueueRequestsFromMapFunc(r.enqueueAllResolvers)).
 		WithEventFilter(predicate.GenerationChangedPredicate{}).
 		Complete(r)
 }
@@ -0,0 +1,72 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// electResolver returns the effective Resolver of the cluster. All Resolvers configure the same VPC, so only the
+// oldest Resolver not being deleted is reconciled, ties are broken by namespace and name.
+func (r *Reconciler) electResolver(ctx context.Context) (*srcv1.Resolver, error) {
+	var resolvers srcv1.ResolverList
+	if err := r.List(ctx, &resolvers); err != nil {
+		return nil, fmt.Errorf("unable to list resolvers: %w", err)
+	}
+	var candidates []srcv1.Resolver
+	for _, resolver := range resolvers.Items {
+		if resolver.DeletionTimestamp.IsZero() {
+			candidates = append(candidates, resolver)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return &candidates[0], nil
+}
+
+// enqueueAllResolvers re-evaluates the election of every Resolver when a Resolver is created or deleted
+func (r *Reconciler) enqueueAllResolvers(obj client.Object) []reconcile.Request {
+	var resolvers srcv1.ResolverList
+	if err := r.List(context.Background(), &resolvers); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, resolver := range resolvers.Items {
+		if resolver.Namespace != obj.GetNamespace() || resolver.Name != obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&resolver)})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53resolver"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// reconcileEndpoint creates the Route 53 Resolver endpoint of a Resolver in the given direction and keeps its
+// IP addresses in the subnets of the spec. The id of the endpoint is stored in id.
+func (r *Reconciler) reconcileEndpoint(ctx context.Context, dns *srcv1.Resolver, direction string, spec *srcv1.ResolverEndpoint, id **string) (*route53resolver.ResolverEndpoint, error) {
+	endpoint, err := r.findEndpoint(ctx, dns, direction, *id)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil {
+		var ipAddresses []*route53resolver.IpAddressRequest
+		for _, ipAddress := range endpointIPAddresses(spec) {
+			ipAddresses = append(ipAddresses, &route53resolver.IpAddressRequest{SubnetId: ipAddress.SubnetId, Ip: ipAddress.Ip})
+		}
+		out, err := r.Route53Resolver.CreateResolverEndpointWithContext(ctx, &route53resolver.CreateResolverEndpointInput{
+			Name:             aws.String(endpointName(dns, direction)),
+			Direction:        aws.String(direction),
+			IpAddresses:      ipAddresses,
+			SecurityGroupIds: aws.StringSlice(spec.SecurityGroupIDs),
+			CreatorRequestId: creatorRequestID(dns, "endpoint", endpointName(dns, direction)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create %s route 53 resolver endpoint: %w", strings.ToLower(direction), err)
+		}
+		*id = out.ResolverEndpoint.Id
+		return out.ResolverEndpoint, nil
+	}
+	*id = endpoint.Id
+
+	if !equalStrings(aws.StringValueSlice(endpoint.SecurityGroupIds), spec.SecurityGroupIDs) {
+		// security groups of an endpoint can not be updated, the Resolver has to be recreated to change them
+		log.FromContext(ctx).Info("security groups of route 53 resolver endpoint differ from the spec", "id", *endpoint.Id,
+			"actual", aws.StringValueSlice(endpoint.SecurityGroupIds), "desired", spec.SecurityGroupIDs)
+	}
+	if aws.StringValue(endpoint.Status) != route53resolver.ResolverEndpointStatusOperational {
+		return endpoint, nil
+	}
+	if err := r.reconcileEndpointSubnets(ctx, endpoint, spec); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// reconcileEndpointSubnets adds the IP addresses of the spec missing from the endpoint, and an IP address in each subnet of
+// the spec without one, then removes the IP addresses in subnets no longer in the spec or no longer pinned
+func (r *Reconciler) reconcileEndpointSubnets(ctx context.Context, endpoint *route53resolver.ResolverEndpoint, spec *srcv1.ResolverEndpoint) error {
+	ipAddresses, err := r.listEndpointIPAddresses(ctx, *endpoint.Id)
+	if err != nil {
+		return err
+	}
+	desired := map[string]bool{}
+	for _, subnetID := range spec.SubnetIDs {
+		desired[subnetID] = true
+	}
+	pinned := map[string]map[string]bool{}
+	for _, ipAddress := range spec.IPAddresses {
+		if pinned[ipAddress.SubnetID] == nil {
+			pinned[ipAddress.SubnetID] = map[string]bool{}
+		}
+		pinned[ipAddress.SubnetID][ipAddress.IP] = true
+	}
+	keep := func(ipAddress *route53resolver.IpAddressResponse) bool {
+		subnetID := aws.StringValue(ipAddress.SubnetId)
+		return desired[subnetID] && (len(pinned[subnetID]) == 0 || pinned[subnetID][aws.StringValue(ipAddress.Ip)])
+	}
+	actual := map[string]bool{}
+	for _, ipAddress := range ipAddresses {
+		if keep(ipAddress) {
+			actual[aws.StringValue(ipAddress.SubnetId)] = true
+			actual[aws.StringValue(ipAddress.Ip)] = true
+		}
+	}
+	for _, ipAddress := range endpointIPAddresses(spec) {
+		if ipAddress.Ip != nil && actual[*ipAddress.Ip] || ipAddress.Ip == nil && actual[*ipAddress.SubnetId] {
+			continue
+		}
+		if _, err := r.Route53Resolver.AssociateResolverEndpointIpAddressWithContext(ctx, &route53resolver.AssociateResolverEndpointIpAddressInput{
+			ResolverEndpointId: endpoint.Id,
+			IpAddress:          ipAddress,
+		}); err != nil {
+			return fmt.Errorf("unable to add an ip address in subnet %s to route 53 resolver endpoint %s: %w", *ipAddress.SubnetId, *endpoint.Id, err)
+		}
+	}
+	for _, ipAddress := range ipAddresses {
+		if keep(ipAddress) {
+			continue
+		}
+		if _, err := r.Route53Resolver.DisassociateResolverEndpointIpAddressWithContext(ctx, &route53resolver.DisassociateResolverEndpointIpAddressInput{
+			ResolverEndpointId: endpoint.Id,
+			IpAddress:          &route53resolver.IpAddressUpdate{IpId: ipAddress.IpId},
+		}); err != nil {
+			return fmt.Errorf("unable to remove ip address %s from route 53 resolver endpoint %s: %w", aws.StringValue(ipAddress.Ip), *endpoint.Id, err)
+		}
+	}
+	return nil
+}
+
+// deleteEndpoint deletes the Route 53 Resolver endpoint of a Resolver in the given direction and clears its id
+func (r *Reconciler) deleteEndpoint(ctx context.Context, dns *srcv1.Resolver, direction string, id **string) error {
+	endpoint, err := r.findEndpoint(ctx, dns, direction, *id)
+	if err != nil {
+		return err
+	}
+	if endpoint != nil && aws.StringValue(endpoint.Status) != route53resolver.ResolverEndpointStatusDeleting {
+		if _, err := r.Route53Resolver.DeleteResolverEndpointWithContext(ctx, &route53resolver.DeleteResolverEndpointInput{
+			ResolverEndpointId: endpoint.Id,
+		}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to delete route 53 resolver endpoint %s: %w", *endpoint.Id, err)
+		}
+	}
+	*id = nil
+	return nil
+}
+
+// findEndpoint returns the endpoint of a Resolver by id, or by name if the status was lost
+func (r *Reconciler) findEndpoint(ctx context.Context, dns *srcv1.Resolver, direction string, id *string) (*route53resolver.ResolverEndpoint, error) {
+	if id != nil {
+		out, err := r.Route53Resolver.GetResolverEndpointWithContext(ctx, &route53resolver.GetResolverEndpointInput{
+			ResolverEndpointId: id,
+		})
+		if err == nil {
+			return out.ResolverEndpoint, nil
+		}
+		if !isNotFound(err) {
+			return nil, fmt.Errorf("unable to get route 53 resolver endpoint %s: %w", *id, err)
+		}
+	}
+	var endpoint *route53resolver.ResolverEndpoint
+	if err := r.Route53Resolver.ListResolverEndpointsPagesWithContext(ctx, &route53resolver.ListResolverEndpointsInput{
+		Filters: []*route53resolver.Filter{
+			{Name: aws.String("Name"), Values: []*string{aws.String(endpointName(dns, direction))}},
+			{Name: aws.String("Direction"), Values: []*string{aws.String(direction)}},
+		},
+	}, func(out *route53resolver.ListResolverEndpointsOutput, _ bool) bool {
+		if len(out.ResolverEndpoints) > 0 {
+			endpoint = out.ResolverEndpoints[0]
+		}
+		return endpoint == nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list route 53 resolver endpoints: %w", err)
+	}
+	return endpoint, nil
+}
+
+func (r *Reconciler) listEndpointIPAddresses(ctx context.Context, endpointID string) ([]*route53resolver.IpAddressResponse, error) {
+	var ipAddresses []*route53resolver.IpAddressResponse
+	if err := r.Route53Resolver.ListResolverEndpointIpAddressesPagesWithContext(ctx, &route53resolver.ListResolverEndpointIpAddressesInput{
+		ResolverEndpointId: aws.String(endpointID),
+	}, func(out *route53resolver.ListResolverEndpointIpAddressesOutput, _ bool) bool {
+		ipAddresses = append(ipAddresses, out.IpAddresses...)
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list ip addresses of route 53 resolver endpoint %s: %w", endpointID, err)
+	}
+	return ipAddresses, nil
+}
+
+// endpointIPAddresses returns the IP addresses an endpoint should have: the pinned IP addresses of the spec, and an
+// IP address assigned by Route 53 Resolver in each subnet without a pinned one
+func endpointIPAddresses(spec *srcv1.ResolverEndpoint) []*route53resolver.IpAddressUpdate {
+	var ipAddresses []*route53resolver.IpAddressUpdate
+	for _, subnetID := range spec.SubnetIDs {
+		pinned := false
+		for _, ipAddress := range spec.IPAddresses {
+			if ipAddress.SubnetID == subnetID {
+				ipAddresses = append(ipAddresses, &route53resolver.IpAddressUpdate{SubnetId: aws.String(subnetID), Ip: aws.String(ipAddress.IP)})
+				pinned = true
+			}
+		}
+		if !pinned {
+			ipAddresses = append(ipAddresses, &route53resolver.IpAddressUpdate{SubnetId: aws.String(subnetID)})
+		}
+	}
+	return ipAddresses
+}
+
+func endpointName(dns *srcv1.Resolver, direction string) string {
+	return resourceName(dns, strings.ToLower(direction))
+}
+
+// equalStrings returns true if both slices hold the same strings in any order
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
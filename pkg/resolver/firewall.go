@@ -0,0 +1,570 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53resolver"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// reconcileFirewall creates the DNS Firewall domain lists and rule groups of a Resolver and associates the rule groups
+// with the cluster's VPC. Domain lists removed from the spec are only deleted once no rule group refers to them.
+func (r *Reconciler) reconcileFirewall(ctx context.Context, dns *srcv1.Resolver) error {
+	var errs []error
+	domainListIDs := map[string]*string{}
+	var domainListStatuses []srcv1.FirewallDomainListStatus
+	for _, list := range dns.Spec.FirewallDomainLists {
+		status := srcv1.FirewallDomainListStatus{Name: list.Name}
+		if existing := findFirewallDomainListStatus(dns.Status.FirewallDomainLists, list.Name); existing != nil {
+			status = *existing
+		}
+		if err := r.reconcileFirewallDomainList(ctx, dns, list, &status); err != nil {
+			errs = append(errs, fmt.Errorf("firewall domain list %s: %w", list.Name, err))
+		} else {
+			domainListIDs[list.Name] = status.DomainListID
+		}
+		domainListStatuses = append(domainListStatuses, status)
+	}
+
+	var ruleGroupStatuses []srcv1.FirewallRuleGroupStatus
+	for _, group := range dns.Spec.FirewallRuleGroups {
+		status := srcv1.FirewallRuleGroupStatus{Name: group.Name}
+		if existing := findFirewallRuleGroupStatus(dns.Status.FirewallRuleGroups, group.Name); existing != nil {
+			status = *existing
+		}
+		err := r.reconcileFirewallRuleGroup(ctx, dns, group, &status, domainListIDs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("firewall rule group %s: %w", group.Name, err))
+		}
+		switch {
+		case err != nil:
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               srcv1.ConditionTypeReady,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: dns.Generation,
+				Reason:             "ReconcileFailed",
+				Message:            err.Error(),
+			})
+		case aws.StringValue(status.AssociationStatus) != route53resolver.FirewallRuleGroupAssociationStatusComplete:
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               srcv1.ConditionTypeReady,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: dns.Generation,
+				Reason:             "AssociationInProgress",
+				Message:            fmt.Sprintf("Rule group association is %s", aws.StringValue(status.AssociationStatus)),
+			})
+		default:
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               srcv1.ConditionTypeReady,
+				Status:             metav1.ConditionTrue,
+				ObservedGeneration: dns.Generation,
+				Reason:             "Associated",
+				Message:            "Rule group is associated with the cluster's VPC",
+			})
+		}
+		ruleGroupStatuses = append(ruleGroupStatuses, status)
+	}
+
+	// rule groups and domain lists removed from the spec are kept in the status until they are deleted
+	for _, status := range dns.Status.FirewallRuleGroups {
+		if findFirewallRuleGroup(dns.Spec.FirewallRuleGroups, status.Name) != nil {
+			continue
+		}
+		if err := r.deleteFirewallRuleGroup(ctx, dns, &status); err != nil {
+			errs = append(errs, fmt.Errorf("firewall rule group %s: %w", status.Name, err))
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               srcv1.ConditionTypeReady,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: dns.Generation,
+				Reason:             "DeleteFailed",
+				Message:            err.Error(),
+			})
+			ruleGroupStatuses = append(ruleGroupStatuses, status)
+		}
+	}
+	dns.Status.FirewallRuleGroups = ruleGroupStatuses
+	for _, status := range dns.Status.FirewallDomainLists {
+		if findFirewallDomainList(dns.Spec.FirewallDomainLists, status.Name) != nil {
+			continue
+		}
+		if len(errs) > 0 {
+			domainListStatuses = append(domainListStatuses, status)
+			continue
+		}
+		if err := r.deleteFirewallDomainList(ctx, dns, &status); err != nil {
+			errs = append(errs, fmt.Errorf("firewall domain list %s: %w", status.Name, err))
+			domainListStatuses = append(domainListStatuses, status)
+		}
+	}
+	dns.Status.FirewallDomainLists = domainListStatuses
+	return utilerrors.NewAggregate(errs)
+}
+
+// deleteFirewall deletes the DNS Firewall rule groups and domain lists of a Resolver
+func (r *Reconciler) deleteFirewall(ctx context.Context, dns *srcv1.Resolver) error {
+	var errs []error
+	var ruleGroupStatuses []srcv1.FirewallRuleGroupStatus
+	for _, status := range withFirewallRuleGroupsFromSpec(dns) {
+		if err := r.deleteFirewallRuleGroup(ctx, dns, &status); err != nil {
+			errs = append(errs, fmt.Errorf("firewall rule group %s: %w", status.Name, err))
+			ruleGroupStatuses = append(ruleGroupStatuses, status)
+		}
+	}
+	dns.Status.FirewallRuleGroups = ruleGroupStatuses
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+	var domainListStatuses []srcv1.FirewallDomainListStatus
+	for _, status := range withFirewallDomainListsFromSpec(dns) {
+		if err := r.deleteFirewallDomainList(ctx, dns, &status); err != nil {
+			errs = append(errs, fmt.Errorf("firewall domain list %s: %w", status.Name, err))
+			domainListStatuses = append(domainListStatuses, status)
+		}
+	}
+	dns.Status.FirewallDomainLists = domainListStatuses
+	return utilerrors.NewAggregate(errs)
+}
+
+func (r *Reconciler) reconcileFirewallDomainList(ctx context.Context, dns *srcv1.Resolver, list srcv1.FirewallDomainList, status *srcv1.FirewallDomainListStatus) error {
+	id, err := r.findFirewallDomainList(ctx, dns, list.Name, status.DomainListID)
+	if err != nil {
+		return err
+	}
+	if id == nil {
+		out, err := r.Route53Resolver.CreateFirewallDomainListWithContext(ctx, &route53resolver.CreateFirewallDomainListInput{
+			Name:             aws.String(resourceName(dns, list.Name)),
+			CreatorRequestId: creatorRequestID(dns, "firewall-domain-list", resourceName(dns, list.Name)),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create route 53 resolver firewall domain list: %w", err)
+		}
+		id = out.FirewallDomainList.Id
+	}
+	status.DomainListID = id
+
+	var domains []string
+	if err := r.Route53Resolver.ListFirewallDomainsPagesWithContext(ctx, &route53resolver.ListFirewallDomainsInput{
+		FirewallDomainListId: id,
+	}, func(out *route53resolver.ListFirewallDomainsOutput, _ bool) bool {
+		domains = append(domains, normalizeDomains(aws.StringValueSlice(out.Domains))...)
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to list domains of route 53 resolver firewall domain list %s: %w", *id, err)
+	}
+	if equalStrings(domains, normalizeDomains(list.Domains)) {
+		return nil
+	}
+	if _, err := r.Route53Resolver.UpdateFirewallDomainsWithContext(ctx, &route53resolver.UpdateFirewallDomainsInput{
+		FirewallDomainListId: id,
+		Operation:            aws.String(route53resolver.FirewallDomainUpdateOperationReplace),
+		Domains:              aws.StringSlice(list.Domains),
+	}); err != nil {
+		return fmt.Errorf("unable to update domains of route 53 resolver firewall domain list %s: %w", *id, err)
+	}
+	return nil
+}
+
+func (r *Reconciler) deleteFirewallDomainList(ctx context.Context, dns *srcv1.Resolver, status *srcv1.FirewallDomainListStatus) error {
+	id, err := r.findFirewallDomainList(ctx, dns, status.Name, status.DomainListID)
+	if err != nil {
+		return err
+	}
+	if id != nil {
+		if _, err := r.Route53Resolver.DeleteFirewallDomainListWithContext(ctx, &route53resolver.DeleteFirewallDomainListInput{
+			FirewallDomainListId: id,
+		}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to delete route 53 resolver firewall domain list %s: %w", *id, err)
+		}
+	}
+	status.DomainListID = nil
+	return nil
+}
+
+// findFirewallDomainList returns the id of the DNS Firewall domain list of a Resolver by id, or by name if the status was lost
+func (r *Reconciler) findFirewallDomainList(ctx context.Context, dns *srcv1.Resolver, name string, id *string) (*string, error) {
+	if id != nil {
+		_, err := r.Route53Resolver.GetFirewallDomainListWithContext(ctx, &route53resolver.GetFirewallDomainListInput{
+			FirewallDomainListId: id,
+		})
+		if err == nil {
+			return id, nil
+		}
+		if !isNotFound(err) {
+			return nil, fmt.Errorf("unable to get route 53 resolver firewall domain list %s: %w", *id, err)
+		}
+	}
+	var found *string
+	if err := r.Route53Resolver.ListFirewallDomainListsPagesWithContext(ctx, &route53resolver.ListFirewallDomainListsInput{}, func(out *route53resolver.ListFirewallDomainListsOutput, _ bool) bool {
+		for _, list := range out.FirewallDomainLists {
+			if aws.StringValue(list.Name) == resourceName(dns, name) && list.ManagedOwnerName == nil {
+				found = list.Id
+				return false
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list route 53 resolver firewall domain lists: %w", err)
+	}
+	return found, nil
+}
+
+func (r *Reconciler) reconcileFirewallRuleGroup(ctx context.Context, dns *srcv1.Resolver, group srcv1.FirewallRuleGroup, status *srcv1.FirewallRuleGroupStatus, domainListIDs map[string]*string) error {
+	vpcID, err := r.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
+	}
+	id, err := r.findFirewallRuleGroup(ctx, dns, group.Name, status.RuleGroupID)
+	if err != nil {
+		return err
+	}
+	if id == nil {
+		out, err := r.Route53Resolver.CreateFirewallRuleGroupWithContext(ctx, &route53resolver.CreateFirewallRuleGroupInput{
+			Name:             aws.String(resourceName(dns, group.Name)),
+			CreatorRequestId: creatorRequestID(dns, "firewall-rule-group", resourceName(dns, group.Name)),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create route 53 resolver firewall rule group: %w", err)
+		}
+		id = out.FirewallRuleGroup.Id
+	}
+	status.RuleGroupID = id
+
+	if err := r.reconcileFirewallRules(ctx, dns, *id, group.Rules, domainListIDs); err != nil {
+		return err
+	}
+
+	var associations []*route53resolver.FirewallRuleGroupAssociation
+	if err := r.Route53Resolver.ListFirewallRuleGroupAssociationsPagesWithContext(ctx, &route53resolver.ListFirewallRuleGroupAssociationsInput{
+		FirewallRuleGroupId: id,
+		VpcId:               aws.String(vpcID),
+	}, func(out *route53resolver.ListFirewallRuleGroupAssociationsOutput, _ bool) bool {
+		associations = append(associations, out.FirewallRuleGroupAssociations...)
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to list route 53 resolver firewall rule group associations: %w", err)
+	}
+	for _, association := range associations {
+		status.AssociationID = association.Id
+		status.AssociationStatus = association.Status
+		if aws.Int64Value(association.Priority) == group.Priority {
+			return nil
+		}
+		out, err := r.Route53Resolver.UpdateFirewallRuleGroupAssociationWithContext(ctx, &route53resolver.UpdateFirewallRuleGroupAssociationInput{
+			FirewallRuleGroupAssociationId: association.Id,
+			Priority:                       aws.Int64(group.Priority),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to update priority of route 53 resolver firewall rule group association %s: %w", *association.Id, err)
+		}
+		status.AssociationStatus = out.FirewallRuleGroupAssociation.Status
+		return nil
+	}
+	out, err := r.Route53Resolver.AssociateFirewallRuleGroupWithContext(ctx, &route53resolver.AssociateFirewallRuleGroupInput{
+		Name:                aws.String(resourceName(dns, group.Name)),
+		FirewallRuleGroupId: id,
+		VpcId:               aws.String(vpcID),
+		Priority:            aws.Int64(group.Priority),
+		CreatorRequestId:    creatorRequestID(dns, "firewall-rule-group-association", resourceName(dns, group.Name), vpcID),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to associate route 53 resolver firewall rule group %s with vpc %s: %w", *id, vpcID, err)
+	}
+	status.AssociationID = out.FirewallRuleGroupAssociation.Id
+	status.AssociationStatus = out.FirewallRuleGroupAssociation.Status
+	return nil
+}
+
+// reconcileFirewallRules makes the rules of a rule group match the spec. Rules are prioritized in the order of the spec,
+// and rules changing their priority or domain list are recreated after the obsolete rules are deleted, so that
+// priorities, which must be unique within a rule group, never collide.
+func (r *Reconciler) reconcileFirewallRules(ctx context.Context, dns *srcv1.Resolver, ruleGroupID string, rules []srcv1.FirewallRule, domainListIDs map[string]*string) error {
+	existingRules, err := r.listFirewallRules(ctx, ruleGroupID)
+	if err != nil {
+		return err
+	}
+	desiredRules := map[string]*route53resolver.FirewallRule{}
+	for i, rule := range rules {
+		domainListID, ok := domainListIDs[rule.DomainList]
+		if !ok {
+			return fmt.Errorf("domain list %s of rule %s is not ready", rule.DomainList, rule.Name)
+		}
+		desiredRules[rule.Name] = firewallRule(rule, ruleGroupID, *domainListID, int64(i+1)*100)
+	}
+
+	var updates []*route53resolver.FirewallRule
+	for _, existing := range existingRules {
+		desired, ok := desiredRules[aws.StringValue(existing.Name)]
+		if ok && aws.StringValue(existing.FirewallDomainListId) == aws.StringValue(desired.FirewallDomainListId) &&
+			aws.Int64Value(existing.Priority) == aws.Int64Value(desired.Priority) {
+			if !equalFirewallRules(existing, desired) {
+				updates = append(updates, desired)
+			}
+			delete(desiredRules, aws.StringValue(existing.Name))
+			continue
+		}
+		if _, err := r.Route53Resolver.DeleteFirewallRuleWithContext(ctx, &route53resolver.DeleteFirewallRuleInput{
+			FirewallRuleGroupId:  existing.FirewallRuleGroupId,
+			FirewallDomainListId: existing.FirewallDomainListId,
+		}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to delete route 53 resolver firewall rule %s: %w", aws.StringValue(existing.Name), err)
+		}
+	}
+	for _, rule := range updates {
+		if _, err := r.Route53Resolver.UpdateFirewallRuleWithContext(ctx, &route53resolver.UpdateFirewallRuleInput{
+			FirewallRuleGroupId:  rule.FirewallRuleGroupId,
+			FirewallDomainListId: rule.FirewallDomainListId,
+			Name:                 rule.Name,
+			Priority:             rule.Priority,
+			Action:               rule.Action,
+			BlockResponse:        rule.BlockResponse,
+			BlockOverrideDnsType: rule.BlockOverrideDnsType,
+			BlockOverrideDomain:  rule.BlockOverrideDomain,
+			BlockOverrideTtl:     rule.BlockOverrideTtl,
+		}); err != nil {
+			return fmt.Errorf("unable to update route 53 resolver firewall rule %s: %w", aws.StringValue(rule.Name), err)
+		}
+	}
+	for _, rule := range desiredRules {
+		if _, err := r.Route53Resolver.CreateFirewallRuleWithContext(ctx, &route53resolver.CreateFirewallRuleInput{
+			FirewallRuleGroupId:  rule.FirewallRuleGroupId,
+			FirewallDomainListId: rule.FirewallDomainListId,
+			Name:                 rule.Name,
+			Priority:             rule.Priority,
+			Action:               rule.Action,
+			BlockResponse:        rule.BlockResponse,
+			BlockOverrideDnsType: rule.BlockOverrideDnsType,
+			BlockOverrideDomain:  rule.BlockOverrideDomain,
+			BlockOverrideTtl:     rule.BlockOverrideTtl,
+			CreatorRequestId:     creatorRequestID(dns, "firewall-rule", ruleGroupID, aws.StringValue(rule.Name), aws.StringValue(rule.FirewallDomainListId), fmt.Sprint(aws.Int64Value(rule.Priority))),
+		}); err != nil {
+			return fmt.Errorf("unable to create route 53 resolver firewall rule %s: %w", aws.StringValue(rule.Name), err)
+		}
+	}
+	return nil
+}
+
+// deleteFirewallRuleGroup disassociates a rule group from the cluster's VPC, deletes its rules and then the rule group
+func (r *Reconciler) deleteFirewallRuleGroup(ctx context.Context, dns *srcv1.Resolver, status *srcv1.FirewallRuleGroupStatus) error {
+	id, err := r.findFirewallRuleGroup(ctx, dns, status.Name, status.RuleGroupID)
+	if err != nil {
+		return err
+	}
+	if id == nil {
+		status.RuleGroupID = nil
+		status.AssociationID = nil
+		status.AssociationStatus = nil
+		return nil
+	}
+	vpcID, err := r.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
+	}
+	var associations []*route53resolver.FirewallRuleGroupAssociation
+	if err := r.Route53Resolver.ListFirewallRuleGroupAssociationsPagesWithContext(ctx, &route53resolver.ListFirewallRuleGroupAssociationsInput{
+		FirewallRuleGroupId: id,
+		VpcId:               aws.String(vpcID),
+	}, func(out *route53resolver.ListFirewallRuleGroupAssociationsOutput, _ bool) bool {
+		associations = append(associations, out.FirewallRuleGroupAssociations...)
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to list route 53 resolver firewall rule group associations: %w", err)
+	}
+	for _, association := range associations {
+		if aws.StringValue(association.Status) == route53resolver.FirewallRuleGroupAssociationStatusDeleting {
+			continue
+		}
+		if _, err := r.Route53Resolver.DisassociateFirewallRuleGroupWithContext(ctx, &route53resolver.DisassociateFirewallRuleGroupInput{
+			FirewallRuleGroupAssociationId: association.Id,
+		}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to disassociate route 53 resolver firewall rule group %s from vpc %s: %w", *id, vpcID, err)
+		}
+	}
+	status.AssociationID = nil
+	status.AssociationStatus = nil
+
+	rules, err := r.listFirewallRules(ctx, *id)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if _, err := r.Route53Resolver.DeleteFirewallRuleWithContext(ctx, &route53resolver.DeleteFirewallRuleInput{
+			FirewallRuleGroupId:  rule.FirewallRuleGroupId,
+			FirewallDomainListId: rule.FirewallDomainListId,
+		}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to delete route 53 resolver firewall rule %s: %w", aws.StringValue(rule.Name), err)
+		}
+	}
+	if _, err := r.Route53Resolver.DeleteFirewallRuleGroupWithContext(ctx, &route53resolver.DeleteFirewallRuleGroupInput{
+		FirewallRuleGroupId: id,
+	}); err != nil && !isNotFound(err) {
+		// deletion fails while the disassociation is still in progress and is retried on the next reconcile
+		return fmt.Errorf("unable to delete route 53 resolver firewall rule group %s: %w", *id, err)
+	}
+	status.RuleGroupID = nil
+	return nil
+}
+
+// findFirewallRuleGroup returns the id of the DNS Firewall rule group of a Resolver by id, or by name if the status was lost
+func (r *Reconciler) findFirewallRuleGroup(ctx context.Context, dns *srcv1.Resolver, name string, id *string) (*string, error) {
+	if id != nil {
+		_, err := r.Route53Resolver.GetFirewallRuleGroupWithContext(ctx, &route53resolver.GetFirewallRuleGroupInput{
+			FirewallRuleGroupId: id,
+		})
+		if err == nil {
+			return id, nil
+		}
+		if !isNotFound(err) {
+			return nil, fmt.Errorf("unable to get route 53 resolver firewall rule group %s: %w", *id, err)
+		}
+	}
+	var found *string
+	if err := r.Route53Resolver.ListFirewallRuleGroupsPagesWithContext(ctx, &route53resolver.ListFirewallRuleGroupsInput{}, func(out *route53resolver.ListFirewallRuleGroupsOutput, _ bool) bool {
+		for _, group := range out.FirewallRuleGroups {
+			if aws.StringValue(group.Name) == resourceName(dns, name) {
+				found = group.Id
+				return false
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list route 53 resolver firewall rule groups: %w", err)
+	}
+	return found, nil
+}
+
+func (r *Reconciler) listFirewallRules(ctx context.Context, ruleGroupID string) ([]*route53resolver.FirewallRule, error) {
+	var rules []*route53resolver.FirewallRule
+	if err := r.Route53Resolver.ListFirewallRulesPagesWithContext(ctx, &route53resolver.ListFirewallRulesInput{
+		FirewallRuleGroupId: aws.String(ruleGroupID),
+	}, func(out *route53resolver.ListFirewallRulesOutput, _ bool) bool {
+		rules = append(rules, out.FirewallRules...)
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list rules of route 53 resolver firewall rule group %s: %w", ruleGroupID, err)
+	}
+	return rules, nil
+}
+
+// firewallRule converts a rule of the spec into a Route 53 Resolver DNS Firewall rule
+func firewallRule(rule srcv1.FirewallRule, ruleGroupID string, domainListID string, priority int64) *route53resolver.FirewallRule {
+	firewallRule := &route53resolver.FirewallRule{
+		Name:                 aws.String(rule.Name),
+		FirewallRuleGroupId:  aws.String(ruleGroupID),
+		FirewallDomainListId: aws.String(domainListID),
+		Priority:             aws.Int64(priority),
+		Action:               aws.String(strings.ToUpper(rule.Action)),
+	}
+	if *firewallRule.Action != route53resolver.ActionBlock {
+		return firewallRule
+	}
+	firewallRule.BlockResponse = aws.String(route53resolver.BlockResponseNodata)
+	if rule.BlockResponse != "" {
+		firewallRule.BlockResponse = aws.String(strings.ToUpper(rule.BlockResponse))
+	}
+	if *firewallRule.BlockResponse == route53resolver.BlockResponseOverride {
+		firewallRule.BlockOverrideDnsType = aws.String(route53resolver.BlockOverrideDnsTypeCname)
+		firewallRule.BlockOverrideDomain = rule.BlockOverrideDomain
+		firewallRule.BlockOverrideTtl = rule.BlockOverrideTTL
+	}
+	return firewallRule
+}
+
+func equalFirewallRules(a *route53resolver.FirewallRule, b *route53resolver.FirewallRule) bool {
+	return aws.StringValue(a.Action) == aws.StringValue(b.Action) &&
+		aws.StringValue(a.BlockResponse) == aws.StringValue(b.BlockResponse) &&
+		aws.StringValue(a.BlockOverrideDnsType) == aws.StringValue(b.BlockOverrideDnsType) &&
+		sameDomain(aws.StringValue(a.BlockOverrideDomain), aws.StringValue(b.BlockOverrideDomain)) &&
+		aws.Int64Value(a.BlockOverrideTtl) == aws.Int64Value(b.BlockOverrideTtl)
+}
+
+// normalizeDomains lower cases domains and removes the trailing dot Route 53 Resolver returns
+func normalizeDomains(domains []string) []string {
+	var normalized []string
+	for _, domain := range domains {
+		normalized = append(normalized, strings.ToLower(strings.TrimSuffix(domain, ".")))
+	}
+	return normalized
+}
+
+// withFirewallRuleGroupsFromSpec returns the rule group statuses along with the rule groups of the spec that never made it
+// into the status, so they can be found by name
+func withFirewallRuleGroupsFromSpec(dns *srcv1.Resolver) []srcv1.FirewallRuleGroupStatus {
+	statuses := append([]srcv1.FirewallRuleGroupStatus{}, dns.Status.FirewallRuleGroups...)
+	for _, group := range dns.Spec.FirewallRuleGroups {
+		if findFirewallRuleGroupStatus(statuses, group.Name) == nil {
+			statuses = append(statuses, srcv1.FirewallRuleGroupStatus{Name: group.Name})
+		}
+	}
+	return statuses
+}
+
+// withFirewallDomainListsFromSpec returns the domain list statuses along with the domain lists of the spec that never made it
+// into the status, so they can be found by name
+func withFirewallDomainListsFromSpec(dns *srcv1.Resolver) []srcv1.FirewallDomainListStatus {
+	statuses := append([]srcv1.FirewallDomainListStatus{}, dns.Status.FirewallDomainLists...)
+	for _, list := range dns.Spec.FirewallDomainLists {
+		if findFirewallDomainListStatus(statuses, list.Name) == nil {
+			statuses = append(statuses, srcv1.FirewallDomainListStatus{Name: list.Name})
+		}
+	}
+	return statuses
+}
+
+func findFirewallDomainList(lists []srcv1.FirewallDomainList, name string) *srcv1.FirewallDomainList {
+	for i := range lists {
+		if lists[i].Name == name {
+			return &lists[i]
+		}
+	}
+	return nil
+}
+
+func findFirewallDomainListStatus(statuses []srcv1.FirewallDomainListStatus, name string) *srcv1.FirewallDomainListStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+func findFirewallRuleGroup(groups []srcv1.FirewallRuleGroup, name string) *srcv1.FirewallRuleGroup {
+	for i := range groups {
+		if groups[i].Name == name {
+			return &groups[i]
+		}
+	}
+	return nil
+}
+
+func findFirewallRuleGroupStatus(statuses []srcv1.FirewallRuleGroupStatus, name string) *srcv1.FirewallRuleGroupStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
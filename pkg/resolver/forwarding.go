@@ -0,0 +1,331 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53resolver"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// reconcileForwarding creates the outbound endpoint and the forwarding rules of a Resolver and associates the rules
+// with the cluster's VPC. Each rule reports its own Ready condition, so a failing rule does not hide the state of the others.
+func (r *Reconciler) reconcileForwarding(ctx context.Context, dns *srcv1.Resolver) error {
+	var errs []error
+	var endpointID *string
+	if dns.Spec.OutboundEndpoint != nil {
+		endpoint, err := r.reconcileEndpoint(ctx, dns, route53resolver.ResolverEndpointDirectionOutbound, dns.Spec.OutboundEndpoint, &dns.Status.OutboundEndpointID)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			endpointID = endpoint.Id
+		}
+	}
+
+	var statuses []srcv1.ForwardingRuleStatus
+	for _, rule := range dns.Spec.ForwardingRules {
+		status := srcv1.ForwardingRuleStatus{Name: rule.Name}
+		if existing := findForwardingRuleStatus(dns.Status.ForwardingRules, rule.Name); existing != nil {
+			status = *existing
+		}
+		if err := r.reconcileForwardingRule(ctx, dns, rule, &status, endpointID); err != nil {
+			errs = append(errs, fmt.Errorf("forwarding rule %s: %w", rule.Name, err))
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               srcv1.ConditionTypeReady,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: dns.Generation,
+				Reason:             "ReconcileFailed",
+				Message:            err.Error(),
+			})
+		} else {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               srcv1.ConditionTypeReady,
+				Status:             metav1.ConditionTrue,
+				ObservedGeneration: dns.Generation,
+				Reason:             "Associated",
+				Message:            fmt.Sprintf("Rule for %s is associated with the cluster's VPC", rule.DomainName),
+			})
+		}
+		statuses = append(statuses, status)
+	}
+
+	// rules removed from the spec are kept in the status until they are deleted
+	for _, status := range dns.Status.ForwardingRules {
+		if findForwardingRule(dns.Spec.ForwardingRules, status.Name) != nil {
+			continue
+		}
+		if err := r.deleteForwardingRule(ctx, dns, &status); err != nil {
+			errs = append(errs, fmt.Errorf("forwarding rule %s: %w", status.Name, err))
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               srcv1.ConditionTypeReady,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: dns.Generation,
+				Reason:             "DeleteFailed",
+				Message:            err.Error(),
+			})
+			statuses = append(statuses, status)
+		}
+	}
+	dns.Status.ForwardingRules = statuses
+
+	if dns.Spec.OutboundEndpoint == nil && len(errs) == 0 {
+		if err := r.deleteEndpoint(ctx, dns, route53resolver.ResolverEndpointDirectionOutbound, &dns.Status.OutboundEndpointID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// deleteForwarding deletes the forwarding rules and the outbound endpoint of a Resolver
+func (r *Reconciler) deleteForwarding(ctx context.Context, dns *srcv1.Resolver) error {
+	var errs []error
+	var statuses []srcv1.ForwardingRuleStatus
+	for _, status := range dns.Status.ForwardingRules {
+		if err := r.deleteForwardingRule(ctx, dns, &status); err != nil {
+			errs = append(errs, fmt.Errorf("forwarding rule %s: %w", status.Name, err))
+			statuses = append(statuses, status)
+		}
+	}
+	// rules that never made it into the status are found by name
+	for _, rule := range dns.Spec.ForwardingRules {
+		if findForwardingRuleStatus(dns.Status.ForwardingRules, rule.Name) != nil {
+			continue
+		}
+		status := srcv1.ForwardingRuleStatus{Name: rule.Name}
+		if err := r.deleteForwardingRule(ctx, dns, &status); err != nil {
+			errs = append(errs, fmt.Errorf("forwarding rule %s: %w", rule.Name, err))
+			statuses = append(statuses, status)
+		}
+	}
+	dns.Status.ForwardingRules = statuses
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+	return r.deleteEndpoint(ctx, dns, route53resolver.ResolverEndpointDirectionOutbound, &dns.Status.OutboundEndpointID)
+}
+
+func (r *Reconciler) reconcileForwardingRule(ctx context.Context, dns *srcv1.Resolver, rule srcv1.ForwardingRule, status *srcv1.ForwardingRuleStatus, endpointID *string) error {
+	vpcID, err := r.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
+	}
+	ruleType := strings.ToUpper(rule.RuleType)
+	if ruleType == "" {
+		ruleType = route53resolver.RuleTypeOptionForward
+	}
+	var targetIPs []*route53resolver.TargetAddress
+	if ruleType == route53resolver.RuleTypeOptionForward {
+		if endpointID == nil {
+			return fmt.Errorf("forward rules require an operational outbound endpoint")
+		}
+		for _, target := range rule.TargetIPs {
+			port := aws.Int64(53)
+			if target.Port != nil {
+				port = target.Port
+			}
+			targetIPs = append(targetIPs, &route53resolver.TargetAddress{Ip: aws.String(target.IP), Port: port})
+		}
+	} else {
+		endpointID = nil
+	}
+
+	resolverRule, err := r.findResolverRule(ctx, dns, rule.Name, status.RuleID)
+	if err != nil {
+		return err
+	}
+	// the domain name and type of a rule can not be updated, so the rule is replaced
+	if resolverRule != nil && (aws.StringValue(resolverRule.RuleType) != ruleType || !sameDomain(aws.StringValue(resolverRule.DomainName), rule.DomainName)) {
+		if err := r.deleteForwardingRule(ctx, dns, status); err != nil {
+			return err
+		}
+		resolverRule = nil
+	}
+	if resolverRule == nil {
+		out, err := r.Route53Resolver.CreateResolverRuleWithContext(ctx, &route53resolver.CreateResolverRuleInput{
+			Name:               aws.String(resourceName(dns, rule.Name)),
+			DomainName:         aws.String(rule.DomainName),
+			RuleType:           aws.String(ruleType),
+			TargetIps:          targetIPs,
+			ResolverEndpointId: endpointID,
+			CreatorRequestId:   creatorRequestID(dns, "rule", resourceName(dns, rule.Name), ruleType, rule.DomainName),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create route 53 resolver rule: %w", err)
+		}
+		resolverRule = out.ResolverRule
+	} else if ruleType == route53resolver.RuleTypeOptionForward &&
+		(aws.StringValue(resolverRule.ResolverEndpointId) != aws.StringValue(endpointID) || !sameTargets(resolverRule.TargetIps, targetIPs)) {
+		if _, err := r.Route53Resolver.UpdateResolverRuleWithContext(ctx, &route53resolver.UpdateResolverRuleInput{
+			ResolverRuleId: resolverRule.Id,
+			Config: &route53resolver.ResolverRuleConfig{
+				ResolverEndpointId: endpointID,
+				TargetIps:          targetIPs,
+			},
+		}); err != nil {
+			return fmt.Errorf("unable to update route 53 resolver rule %s: %w", *resolverRule.Id, err)
+		}
+	}
+	status.RuleID = resolverRule.Id
+
+	associations, err := r.listResolverRuleAssociations(ctx, *resolverRule.Id, vpcID)
+	if err != nil {
+		return err
+	}
+	for _, association := range associations {
+		if aws.StringValue(association.Status) == route53resolver.ResolverRuleAssociationStatusFailed {
+			return fmt.Errorf("route 53 resolver rule association %s failed: %s", aws.StringValue(association.Id), aws.StringValue(association.StatusMessage))
+		}
+		status.AssociationID = association.Id
+		return nil
+	}
+	out, err := r.Route53Resolver.AssociateResolverRuleWithContext(ctx, &route53resolver.AssociateResolverRuleInput{
+		Name:           aws.String(resourceName(dns, rule.Name)),
+		ResolverRuleId: resolverRule.Id,
+		VPCId:          aws.String(vpcID),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to associate route 53 resolver rule %s with vpc %s: %w", *resolverRule.Id, vpcID, err)
+	}
+	status.AssociationID = out.ResolverRuleAssociation.Id
+	return nil
+}
+
+// deleteForwardingRule disassociates a forwarding rule from the cluster's VPC and deletes it
+func (r *Reconciler) deleteForwardingRule(ctx context.Context, dns *srcv1.Resolver, status *srcv1.ForwardingRuleStatus) error {
+	resolverRule, err := r.findResolverRule(ctx, dns, status.Name, status.RuleID)
+	if err != nil {
+		return err
+	}
+	if resolverRule == nil {
+		status.RuleID = nil
+		status.AssociationID = nil
+		return nil
+	}
+	vpcID, err := r.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
+	}
+	associations, err := r.listResolverRuleAssociations(ctx, *resolverRule.Id, vpcID)
+	if err != nil {
+		return err
+	}
+	for _, association := range associations {
+		if aws.StringValue(association.Status) == route53resolver.ResolverRuleAssociationStatusDeleting {
+			continue
+		}
+		if _, err := r.Route53Resolver.DisassociateResolverRuleWithContext(ctx, &route53resolver.DisassociateResolverRuleInput{
+			ResolverRuleId: resolverRule.Id,
+			VPCId:          association.VPCId,
+		}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to disassociate route 53 resolver rule %s from vpc %s: %w", *resolverRule.Id, vpcID, err)
+		}
+	}
+	status.AssociationID = nil
+	if _, err := r.Route53Resolver.DeleteResolverRuleWithContext(ctx, &route53resolver.DeleteResolverRuleInput{
+		ResolverRuleId: resolverRule.Id,
+	}); err != nil && !isNotFound(err) {
+		// deletion fails while the disassociation is still in progress and is retried on the next reconcile
+		return fmt.Errorf("unable to delete route 53 resolver rule %s: %w", *resolverRule.Id, err)
+	}
+	status.RuleID = nil
+	return nil
+}
+
+// findResolverRule returns the Route 53 Resolver rule of a forwarding rule by id, or by name if the status was lost
+func (r *Reconciler) findResolverRule(ctx context.Context, dns *srcv1.Resolver, name string, id *string) (*route53resolver.ResolverRule, error) {
+	if id != nil {
+		out, err := r.Route53Resolver.GetResolverRuleWithContext(ctx, &route53resolver.GetResolverRuleInput{
+			ResolverRuleId: id,
+		})
+		if err == nil {
+			return out.ResolverRule, nil
+		}
+		if !isNotFound(err) {
+			return nil, fmt.Errorf("unable to get route 53 resolver rule %s: %w", *id, err)
+		}
+	}
+	var resolverRule *route53resolver.ResolverRule
+	if err := r.Route53Resolver.ListResolverRulesPagesWithContext(ctx, &route53resolver.ListResolverRulesInput{
+		Filters: []*route53resolver.Filter{{Name: aws.String("Name"), Values: []*string{aws.String(resourceName(dns, name))}}},
+	}, func(out *route53resolver.ListResolverRulesOutput, _ bool) bool {
+		if len(out.ResolverRules) > 0 {
+			resolverRule = out.ResolverRules[0]
+		}
+		return resolverRule == nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list route 53 resolver rules: %w", err)
+	}
+	return resolverRule, nil
+}
+
+func (r *Reconciler) listResolverRuleAssociations(ctx context.Context, ruleID string, vpcID string) ([]*route53resolver.ResolverRuleAssociation, error) {
+	var associations []*route53resolver.ResolverRuleAssociation
+	if err := r.Route53Resolver.ListResolverRuleAssociationsPagesWithContext(ctx, &route53resolver.ListResolverRuleAssociationsInput{
+		Filters: []*route53resolver.Filter{
+			{Name: aws.String("ResolverRuleId"), Values: []*string{aws.String(ruleID)}},
+			{Name: aws.String("VPCId"), Values: []*string{aws.String(vpcID)}},
+		},
+	}, func(out *route53resolver.ListResolverRuleAssociationsOutput, _ bool) bool {
+		associations = append(associations, out.ResolverRuleAssociations...)
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list route 53 resolver rule associations: %w", err)
+	}
+	return associations, nil
+}
+
+func findForwardingRule(rules []srcv1.ForwardingRule, name string) *srcv1.ForwardingRule {
+	for i := range rules {
+		if rules[i].Name == name {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func findForwardingRuleStatus(statuses []srcv1.ForwardingRuleStatus, name string) *srcv1.ForwardingRuleStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// sameDomain compares domain names ignoring case and the trailing dot Route 53 Resolver returns
+func sameDomain(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+func sameTargets(a []*route53resolver.TargetAddress, b []*route53resolver.TargetAddress) bool {
+	var as, bs []string
+	for _, t := range a {
+		as = append(as, fmt.Sprintf("%s:%d", aws.StringValue(t.Ip), aws.Int64Value(t.Port)))
+	}
+	for _, t := range b {
+		bs = append(bs, fmt.Sprintf("%s:%d", aws.StringValue(t.Ip), aws.Int64Value(t.Port)))
+	}
+	return equalStrings(as, bs)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53resolver"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// reconcileInboundEndpoint creates the inbound endpoint of a Resolver and publishes its IP addresses in the status,
+// so they can be configured as the targets of conditional forwarders outside of the VPC
+func (r *Reconciler) reconcileInboundEndpoint(ctx context.Context, dns *srcv1.Resolver) error {
+	if dns.Spec.InboundEndpoint == nil {
+		return r.deleteInboundEndpoint(ctx, dns)
+	}
+	endpoint, err := r.reconcileEndpoint(ctx, dns, route53resolver.ResolverEndpointDirectionInbound, dns.Spec.InboundEndpoint, &dns.Status.InboundEndpointID)
+	if err != nil {
+		return err
+	}
+	ipAddresses, err := r.listEndpointIPAddresses(ctx, *endpoint.Id)
+	if err != nil {
+		return err
+	}
+	var ips []string
+	for _, ipAddress := range ipAddresses {
+		if ipAddress.Ip != nil && aws.StringValue(ipAddress.Status) != route53resolver.IpAddressStatusDeleting {
+			ips = append(ips, *ipAddress.Ip)
+		}
+	}
+	sort.Strings(ips)
+	dns.Status.InboundEndpointIPs = ips
+	return nil
+}
+
+// deleteInboundEndpoint deletes the inbound endpoint of a Resolver
+func (r *Reconciler) deleteInboundEndpoint(ctx context.Context, dns *srcv1.Resolver) error {
+	if err := r.deleteEndpoint(ctx, dns, route53resolver.ResolverEndpointDirectionInbound, &dns.Status.InboundEndpointID); err != nil {
+		return err
+	}
+	dns.Status.InboundEndpointIPs = nil
+	return nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53resolver"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// reconcileQueryLogConfig creates the Route 53 Resolver query log config of a Resolver and associates it with the
+// cluster's VPC. The query log config is replaced when its destination drifts from the spec, since destinations can
+// not be updated, and it is deleted when query logging is removed from the spec.
+func (r *Reconciler) reconcileQueryLogConfig(ctx context.Context, dns *srcv1.Resolver) error {
+	spec := dns.Spec.EffectiveQueryLogConfig()
+	if spec == nil {
+		return r.deleteQueryLogConfig(ctx, dns)
+	}
+	vpcID, err := r.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
+	}
+	config, err := r.findQueryLogConfig(ctx, dns)
+	if err != nil {
+		return err
+	}
+	if config != nil && aws.StringValue(config.DestinationArn) != spec.DestinationARN {
+		log.FromContext(ctx).Info("replacing query log config with a new destination", "id", aws.StringValue(config.Id),
+			"from", aws.StringValue(config.DestinationArn), "to", spec.DestinationARN)
+		if err := r.deleteQueryLogConfig(ctx, dns); err != nil {
+			return err
+		}
+		config = nil
+	}
+	if config == nil {
+		out, err := r.Route53Resolver.CreateResolverQueryLogConfigWithContext(ctx, &route53resolver.CreateResolverQueryLogConfigInput{
+			Name:             aws.String(queryLogConfigName(dns)),
+			DestinationArn:   aws.String(spec.DestinationARN),
+			CreatorRequestId: creatorRequestID(dns, "query-log-config", queryLogConfigName(dns), spec.DestinationARN),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create route 53 resolver query log config: %w", err)
+		}
+		config = out.ResolverQueryLogConfig
+	}
+	dns.Status.QueryLogConfigID = config.Id
+
+	associations, err := r.listQueryLogConfigAssociations(ctx, *config.Id, vpcID)
+	if err != nil {
+		return err
+	}
+	for _, association := range associations {
+		switch aws.StringValue(association.Status) {
+		case route53resolver.ResolverQueryLogConfigAssociationStatusFailed:
+			return fmt.Errorf("route 53 resolver query log config association %s failed: %s", aws.StringValue(association.Id), aws.StringValue(association.ErrorMessage))
+		case route53resolver.ResolverQueryLogConfigAssociationStatusDeleting:
+			// the vpc can only be associated again once the disassociation completes, retried on the next reconcile
+			dns.Status.QueryLogConfigAssociationID = nil
+			return fmt.Errorf("route 53 resolver query log config association %s is being deleted", aws.StringValue(association.Id))
+		}
+		dns.Status.QueryLogConfigAssociationID = association.Id
+		return nil
+	}
+	out, err := r.Route53Resolver.AssociateResolverQueryLogConfigWithContext(ctx, &route53resolver.AssociateResolverQueryLogConfigInput{
+		ResolverQueryLogConfigId: config.Id,
+		ResourceId:               aws.String(vpcID),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to associate route 53 resolver query log config %s with vpc %s: %w", *config.Id, vpcID, err)
+	}
+	dns.Status.QueryLogConfigAssociationID = out.ResolverQueryLogConfigAssociation.Id
+	return nil
+}
+
+// deleteQueryLogConfig disassociates the query log config of a Resolver from the cluster's VPC and deletes it
+func (r *Reconciler) deleteQueryLogConfig(ctx context.Context, dns *srcv1.Resolver) error {
+	config, err := r.findQueryLogConfig(ctx, dns)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		dns.Status.QueryLogConfigID = nil
+		dns.Status.QueryLogConfigAssociationID = nil
+		return nil
+	}
+	vpcID, err := r.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
+	}
+	associations, err := r.listQueryLogConfigAssociations(ctx, *config.Id, vpcID)
+	if err != nil {
+		return err
+	}
+	for _, association := range associations {
+		if aws.StringValue(association.Status) == route53resolver.ResolverQueryLogConfigAssociationStatusDeleting {
+			continue
+		}
+		if _, err := r.Route53Resolver.DisassociateResolverQueryLogConfigWithContext(ctx, &route53resolver.DisassociateResolverQueryLogConfigInput{
+			ResolverQueryLogConfigId: config.Id,
+			ResourceId:               association.ResourceId,
+		}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to disassociate route 53 resolver query log config %s from vpc %s: %w", *config.Id, vpcID, err)
+		}
+	}
+	dns.Status.QueryLogConfigAssociationID = nil
+	if _, err := r.Route53Resolver.DeleteResolverQueryLogConfigWithContext(ctx, &route53resolver.DeleteResolverQueryLogConfigInput{
+		ResolverQueryLogConfigId: config.Id,
+	}); err != nil && !isNotFound(err) {
+		// deletion fails while the disassociation is still in progress and is retried on the next reconcile
+		return fmt.Errorf("unable to delete route 53 resolver query log config %s: %w", *config.Id, err)
+	}
+	dns.Status.QueryLogConfigID = nil
+	return nil
+}
+
+// findQueryLogConfig returns the query log config of a Resolver by the id in its status, or by name if the status was lost
+func (r *Reconciler) findQueryLogConfig(ctx context.Context, dns *srcv1.Resolver) (*route53resolver.ResolverQueryLogConfig, error) {
+	if dns.Status.QueryLogConfigID != nil {
+		out, err := r.Route53Resolver.GetResolverQueryLogConfigWithContext(ctx, &route53resolver.GetResolverQueryLogConfigInput{
+			ResolverQueryLogConfigId: dns.Status.QueryLogConfigID,
+		})
+		if err == nil {
+			return out.ResolverQueryLogConfig, nil
+		}
+		if !isNotFound(err) {
+			return nil, fmt.Errorf("unable to get route 53 resolver query log config %s: %w", *dns.Status.QueryLogConfigID, err)
+		}
+	}
+	var config *route53resolver.ResolverQueryLogConfig
+	if err := r.Route53Resolver.ListResolverQueryLogConfigsPagesWithContext(ctx, &route53resolver.ListResolverQueryLogConfigsInput{
+		Filters: []*route53resolver.Filter{{Name: aws.String("Name"), Values: []*string{aws.String(queryLogConfigName(dns))}}},
+	}, func(out *route53resolver.ListResolverQueryLogConfigsOutput, _ bool) bool {
+		if len(out.ResolverQueryLogConfigs) > 0 {
+			config = out.ResolverQueryLogConfigs[0]
+		}
+		return config == nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list route 53 resolver query log configs: %w", err)
+	}
+	return config, nil
+}
+
+func (r *Reconciler) listQueryLogConfigAssociations(ctx context.Context, configID string, vpcID string) ([]*route53resolver.ResolverQueryLogConfigAssociation, error) {
+	var associations []*route53resolver.ResolverQueryLogConfigAssociation
+	if err := r.Route53Resolver.ListResolverQueryLogConfigAssociationsPagesWithContext(ctx, &route53resolver.ListResolverQueryLogConfigAssociationsInput{
+		Filters: []*route53resolver.Filter{
+			{Name: aws.String("ResolverQueryLogConfigId"), Values: []*string{aws.String(configID)}},
+			{Name: aws.String("ResourceId"), Values: []*string{aws.String(vpcID)}},
+		},
+	}, func(out *route53resolver.ListResolverQueryLogConfigAssociationsOutput, _ bool) bool {
+		associations = append(associations, out.ResolverQueryLogConfigAssociations...)
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list route 53 resolver query log config associations: %w", err)
+	}
+	return associations, nil
+}
+
+func queryLogConfigName(dns *srcv1.Resolver) string {
+	return resourceName(dns)
+}
+
+func isNotFound(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == route53resolver.ErrCodeResourceNotFoundException
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	srcv1 "github.com/bwagner5/k53/pkg/api/v1"
+)
+
+// setFeatureCondition sets the condition of a feature of a Resolver from the error reconciling it
+func setFeatureCondition(dns *srcv1.Resolver, conditionType string, configured bool, err error) {
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: dns.Generation,
+		Reason:             "Reconciled",
+		Message:            fmt.Sprintf("%s is reconciled", conditionType),
+	}
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ReconcileFailed"
+		condition.Message = err.Error()
+	case !configured:
+		condition.Reason = "NotConfigured"
+		condition.Message = fmt.Sprintf("%s is not configured", conditionType)
+	}
+	meta.SetStatusCondition(&dns.Status.Conditions, condition)
+}
+
+// setStatus sets the Ready and Degraded conditions, the observed generation and the last error of a Resolver
+// once all of its features are reconciled. A Resolver is Degraded when it fails to reconcile after it was ready.
+func setStatus(dns *srcv1.Resolver, reason string, err error) {
+	dns.Status.ObservedGeneration = dns.Generation
+	if err == nil {
+		dns.Status.LastError = ""
+		meta.SetStatusCondition(&dns.Status.Conditions, metav1.Condition{
+			Type:               srcv1.ConditionTypeReady,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: dns.Generation,
+			Reason:             "Reconciled",
+			Message:            "All AWS resources of the Resolver are reconciled",
+		})
+		meta.SetStatusCondition(&dns.Status.Conditions, metav1.Condition{
+			Type:               srcv1.ConditionTypeDegraded,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: dns.Generation,
+			Reason:             "Reconciled",
+			Message:            "All AWS resources of the Resolver are reconciled",
+		})
+		return
+	}
+	dns.Status.LastError = err.Error()
+	degraded := meta.IsStatusConditionTrue(dns.Status.Conditions, srcv1.ConditionTypeReady) ||
+		meta.IsStatusConditionTrue(dns.Status.Conditions, srcv1.ConditionTypeDegraded)
+	meta.SetStatusCondition(&dns.Status.Conditions, metav1.Condition{
+		Type:               srcv1.ConditionTypeReady,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: dns.Generation,
+		Reason:             reason,
+		Message:            failedMessage(dns),
+	})
+	condition := metav1.Condition{
+		Type:               srcv1.ConditionTypeDegraded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: dns.Generation,
+		Reason:             "NeverReady",
+		Message:            "The Resolver has not been ready yet",
+	}
+	if degraded {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reason
+		condition.Message = "The AWS resources of the Resolver may reflect an earlier generation of the spec"
+	}
+	meta.SetStatusCondition(&dns.Status.Conditions, condition)
+}
+
+// failedMessage lists the features of a Resolver whose conditions are false
+func failedMessage(dns *srcv1.Resolver) string {
+	var failed []string
+	for _, conditionType := range []string{srcv1.ConditionTypeQueryLogging, srcv1.ConditionTypeForwarding, srcv1.ConditionTypeFirewall, srcv1.ConditionTypeInboundEndpoint} {
+		if meta.IsStatusConditionFalse(dns.Status.Conditions, conditionType) {
+			failed = append(failed, conditionType)
+		}
+	}
+	if len(failed) == 0 {
+		return "Reconciling the Resolver failed, see lastError"
+	}
+	return fmt.Sprintf("Reconciling %s failed, see the conditions of each feature", strings.Join(failed, ", "))
+}
+
+// setConflict marks a Resolver losing the election of the cluster's effective Resolver to owner
+func setConflict(dns *srcv1.Resolver, owner *srcv1.Resolver) {
+	message := fmt.Sprintf("Resolver %s/%s is the effective Resolver of the cluster, this Resolver is not reconciled", owner.Namespace, owner.Name)
+	dns.Status.ObservedGeneration = dns.Generation
+	dns.Status.LastError = ""
+	meta.SetStatusCondition(&dns.Status.Conditions, metav1.Condition{
+		Type:               srcv1.ConditionTypeConflict,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: dns.Generation,
+		Reason:             "NotElected",
+		Message:            message,
+	})
+	meta.SetStatusCondition(&dns.Status.Conditions, metav1.Condition{
+		Type:               srcv1.ConditionTypeReady,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: dns.Generation,
+		Reason:             "Conflict",
+		Message:            message,
+	})
+}
+
+// clearConflict marks a Resolver as the effective Resolver of the cluster
+func clearConflict(dns *srcv1.Resolver) {
+	meta.SetStatusCondition(&dns.Status.Conditions, metav1.Condition{
+		Type:               srcv1.ConditionTypeConflict,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: dns.Generation,
+		Reason:             "Elected",
+		Message:            "This Resolver is the effective Resolver of the cluster",
+	})
+}
+
+// setAssumeRoleCondition sets the AssumeRole condition of a Resolver from the error assuming its role
+func setAssumeRoleCondition(dns *srcv1.Resolver, err error) {
+	condition := metav1.Condition{
+		Type:               srcv1.ConditionTypeAssumeRole,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: dns.Generation,
+		Reason:             "Assumed",
+		Message:            fmt.Sprintf("Role %s is assumed", dns.Spec.AssumeRole.RoleARN),
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "AssumeRoleFailed"
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&dns.Status.Conditions, condition)
+}
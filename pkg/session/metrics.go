@@ -0,0 +1,60 @@
+package session
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "k53",
+		Subsystem: "aws",
+		Name:      "api_requests_total",
+		Help:      "Number of AWS API requests by service and operation, counting every attempt including retries.",
+	}, []string{"service", "operation"})
+	apiErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "k53",
+		Subsystem: "aws",
+		Name:      "api_errors_total",
+		Help:      "Number of failed AWS API requests by service, operation and error code.",
+	}, []string{"service", "operation", "code"})
+	apiThrottles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "k53",
+		Subsystem: "aws",
+		Name:      "api_throttles_total",
+		Help:      "Number of AWS API requests throttled by service and operation.",
+	}, []string{"service", "operation"})
+	vpcInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "k53",
+		Subsystem: "aws",
+		Name:      "vpc_info",
+		Help:      "The cluster's VPC and the method it was discovered with, one of flag, eks, ec2-tags or imds. Always 1.",
+	}, []string{"vpc_id", "method"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiRequests, apiErrors, apiThrottles, vpcInfo)
+}
+
+// withMetrics counts the API requests of every client created from the session, and of the sessions copied from it
+func withMetrics(sess *session.Session) *session.Session {
+	sess.Handlers.CompleteAttempt.PushBack(func(r *request.Request) {
+		service, operation := r.ClientInfo.ServiceName, r.Operation.Name
+		apiRequests.WithLabelValues(service, operation).Inc()
+		if r.Error == nil {
+			return
+		}
+		code := "Unknown"
+		if awsErr, ok := r.Error.(awserr.Error); ok {
+			code = awsErr.Code()
+		}
+		apiErrors.WithLabelValues(service, operation, code).Inc()
+		if r.IsErrorThrottle() {
+			apiThrottles.WithLabelValues(service, operation).Inc()
+		}
+	})
+	return sess
+}
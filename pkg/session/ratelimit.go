@@ -0,0 +1,50 @@
+package session
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// route53Retryer backs off exponentially when Route 53 throttles requests with Throttling or PriorRequestNotComplete,
+// waiting longer than the default retryer since the Route 53 rate limit is shared by the whole account
+var route53Retryer = client.DefaultRetryer{
+	NumMaxRetries:    8,
+	MinRetryDelay:    client.DefaultRetryerMinRetryDelay,
+	MaxRetryDelay:    client.DefaultRetryerMaxRetryDelay,
+	MinThrottleDelay: 500 * time.Millisecond,
+	MaxThrottleDelay: 30 * time.Second,
+}
+
+// withRoute53RateLimit limits the Route 53 requests of every client created from the session, and of the sessions
+// copied from it such as assumed roles, to qps requests per second with bursts of burst requests.
+// Every attempt waits for the limiter, so retries are limited too. A qps of 0 disables the limiter.
+func withRoute53RateLimit(sess *session.Session, qps float64, burst int) *session.Session {
+	sess.Handlers.Validate.PushFront(func(r *request.Request) {
+		if r.ClientInfo.ServiceName == route53.ServiceName {
+			r.Retryer = route53Retryer
+		}
+	})
+	if qps <= 0 {
+		return sess
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	limiter := flowcontrol.NewTokenBucketRateLimiter(float32(qps), burst)
+	// requests are signed after waiting, so that the signature does not age in the queue
+	sess.Handlers.Sign.PushFront(func(r *request.Request) {
+		if r.ClientInfo.ServiceName != route53.ServiceName {
+			return
+		}
+		if err := limiter.Wait(r.Context()); err != nil {
+			r.Error = awserr.New(request.CanceledErrorCode, "waiting for the route 53 rate limiter", err)
+		}
+	})
+	return sess
+}
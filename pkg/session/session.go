@@ -3,30 +3,142 @@ package session
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53resolver"
 	"k8s.io/klog/v2"
 )
 
-func Create(ctx context.Context, version string) *session.Session {
-	sess := withUserAgent(session.Must(session.NewSession(
-		request.WithRetryer(
-			&aws.Config{STSRegionalEndpoint: endpoints.RegionalSTSEndpoint},
-			client.DefaultRetryer{NumMaxRetries: client.DefaultRetryerMaxNumRetries},
-		),
-	)), version)
+// Options configures the AWS session. Everything left empty is taken from the environment as usual,
+// endpoints allow running against local stand-ins for AWS.
+type Options struct {
+	Region string
+	// Profile is the shared config profile to use
+	Profile string
+	// AccessKeyID and SecretAccessKey are static credentials, taking precedence over the profile and the environment
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Route53Endpoint, Route53ResolverEndpoint, EC2Endpoint and EKSEndpoint override the endpoint URLs of the respective services
+	Route53Endpoint         string
+	Route53ResolverEndpoint string
+	EC2Endpoint             string
+	EKSEndpoint             string
+	// IMDSEndpoint overrides the endpoint URL of the EC2 Instance Metadata Service
+	IMDSEndpoint string
+	// Route53QPS and Route53Burst limit the Route 53 requests of the controller, which share the rate limit of
+	// five requests per second of the account with every other tool. A Route53QPS of 0 disables the limit.
+	Route53QPS   float64
+	Route53Burst int
+}
 
-	if *sess.Config.Region == "" {
+func Create(ctx context.Context, version string, opts Options) (*session.Session, error) {
+	cfg := &aws.Config{STSRegionalEndpoint: endpoints.RegionalSTSEndpoint}
+	if opts.Region != "" {
+		cfg.Region = aws.String(opts.Region)
+	}
+	if opts.AccessKeyID != "" {
+		cfg.Credentials = credentials.NewStaticCredentials(opts.AccessKeyID, opts.SecretAccessKey, opts.SessionToken)
+	}
+	customEndpoints := map[string]string{
+		route53.EndpointsID:         opts.Route53Endpoint,
+		route53resolver.EndpointsID: opts.Route53ResolverEndpoint,
+		ec2.EndpointsID:             opts.EC2Endpoint,
+		eks.EndpointsID:             opts.EKSEndpoint,
+	}
+	cfg.EndpointResolver = endpoints.ResolverFunc(func(service, region string, optFns ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if url := customEndpoints[service]; url != "" {
+			return endpoints.ResolvedEndpoint{URL: url, SigningRegion: region}, nil
+		}
+		return endpoints.DefaultResolver().EndpointFor(service, region, optFns...)
+	})
+	sessionOpts := session.Options{
+		Config:          *request.WithRetryer(cfg, client.DefaultRetryer{NumMaxRetries: client.DefaultRetryerMaxNumRetries}),
+		Profile:         opts.Profile,
+		EC2IMDSEndpoint: opts.IMDSEndpoint,
+	}
+	if opts.Profile != "" {
+		sessionOpts.SharedConfigState = session.SharedConfigEnable
+	}
+	sess, err := session.NewSessionWithOptions(sessionOpts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create aws session: %w", err)
+	}
+	sess = withMetrics(withUserAgent(sess, version))
+	sess = withRoute53RateLimit(sess, opts.Route53QPS, opts.Route53Burst)
+
+	if aws.StringValue(sess.Config.Region) == "" {
 		klog.Infof("AWS region not configured, asking EC2 Instance Metadata Service")
-		*sess.Config.Region = getRegionFromIMDS(ctx, sess)
+		region, err := getRegionFromIMDS(ctx, sess)
+		if err != nil {
+			return nil, err
+		}
+		sess.Config.Region = aws.String(region)
 	}
 	klog.Infof("Using AWS region %s", *sess.Config.Region)
-	return sess
+	return sess, nil
+}
+
+// RoleOptions configures an IAM role to assume, e.g. in the account a hosted zone lives in
+type RoleOptions struct {
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+}
+
+type roleSessionKey struct {
+	base *session.Session
+	role RoleOptions
+}
+
+var (
+	roleSessionsMu sync.Mutex
+	roleSessions   = map[roleSessionKey]*session.Session{}
+)
+
+// AssumeRole returns a session using the credentials of the role, assumed with the credentials of sess.
+// Sessions are cached per role, so all clients of a role share its credentials, which are refreshed before they expire.
+func AssumeRole(sess *session.Session, role RoleOptions) *session.Session {
+	roleSessionsMu.Lock()
+	defer roleSessionsMu.Unlock()
+	key := roleSessionKey{base: sess, role: role}
+	if roleSession, ok := roleSessions[key]; ok {
+		return roleSession
+	}
+	sessionName := role.SessionName
+	if sessionName == "" {
+		sessionName = "k53"
+	}
+	creds := stscreds.NewCredentials(sess, role.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = sessionName
+		if role.ExternalID != "" {
+			p.ExternalID = aws.String(role.ExternalID)
+		}
+	})
+	roleSession := sess.Copy(&aws.Config{Credentials: creds})
+	roleSessions[key] = roleSession
+	return roleSession
+}
+
+// VerifyCredentials retrieves the credentials of a session, so that failures assuming a role surface before any
+// API is called. Cached credentials are returned without calling STS.
+func VerifyCredentials(ctx context.Context, sess *session.Session) error {
+	if _, err := sess.Config.Credentials.GetWithContext(ctx); err != nil {
+		return fmt.Errorf("unable to retrieve aws credentials: %w", err)
+	}
+	return nil
 }
 
 // withUserAgent adds a karpenter specific user-agent string to AWS session
@@ -37,10 +149,28 @@ func withUserAgent(sess *session.Session, version string) *session.Session {
 }
 
 // get the current region from EC2 IMDS
-func getRegionFromIMDS(ctx context.Context, sess *session.Session) string {
+func getRegionFromIMDS(ctx context.Context, sess *session.Session) (string, error) {
 	region, err := ec2metadata.New(sess).RegionWithContext(ctx)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to call the metadata server's region API, %s", err))
+		return "", fmt.Errorf("unable to get the region from the metadata server, configure the region explicitly outside of EC2: %w", err)
+	}
+	return region, nil
+}
+
+// vpcIDFromIMDS returns the id of the VPC of the primary network interface from EC2 IMDS
+func vpcIDFromIMDS(ctx context.Context, sess *session.Session) (string, error) {
+	imds := ec2metadata.New(sess)
+	macsResp, err := imds.GetMetadataWithContext(ctx, "/network/interfaces/macs")
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve vpc-id from network interfaces: %v", err)
+	}
+	macs := strings.Split(macsResp, "\n")
+	if len(macs) == 0 {
+		return "", fmt.Errorf("unable to identify primary network interface: %v", err)
+	}
+	vpcID, err := imds.GetMetadataWithContext(ctx, fmt.Sprintf("/network/interfaces/macs/%s/vpc-id", strings.TrimSuffix(macs[0], "/")))
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve vpc-id from primary network interface: %v", err)
 	}
-	return region
+	return vpcID, nil
 }
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+// Methods discovering the cluster's VPC, in the order they are tried. The method used labels the k53_aws_vpc_info metric.
+const (
+	VPCDiscoveryFlag    = "flag"
+	VPCDiscoveryEKS     = "eks"
+	VPCDiscoveryEC2Tags = "ec2-tags"
+	VPCDiscoveryIMDS    = "imds"
+)
+
+// VPCOptions configures how the cluster's VPC is discovered
+type VPCOptions struct {
+	// VPCID is the id of the cluster's VPC, skipping discovery
+	VPCID string
+	// EKSClusterName is the name of the EKS cluster, whose VPC is looked up with EKS DescribeCluster or else the
+	// kubernetes.io/cluster/<name> tag of its VPC or instances. Without it the VPC is only looked up from EC2 IMDS.
+	EKSClusterName string
+}
+
+// VPC discovers the cluster's VPC once and shares it between controllers.
+// Discovery through AWS APIs does not need EC2 IMDS, so it works on Fargate, with an IMDS hop limit of 1,
+// or when the controller runs outside of the cluster.
+type VPC struct {
+	sess *session.Session
+	opts VPCOptions
+
+	mu sync.Mutex
+	id string
+}
+
+// NewVPC returns the VPC discovered with sess, which should use the cluster's account rather than an assumed role
+func NewVPC(sess *session.Session, opts VPCOptions) *VPC {
+	return &VPC{sess: sess, opts: opts}
+}
+
+// ID returns the id of the cluster's VPC, discovering it on the first call. Failed discoveries are retried on the next call.
+func (v *VPC) ID(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.id != "" {
+		return v.id, nil
+	}
+	id, method, err := v.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	klog.Infof("Using vpc %s, discovered with method %s", id, method)
+	vpcInfo.WithLabelValues(id, method).Set(1)
+	v.id = id
+	return id, nil
+}
+
+func (v *VPC) discover(ctx context.Context) (string, string, error) {
+	if v.opts.VPCID != "" {
+		return v.opts.VPCID, VPCDiscoveryFlag, nil
+	}
+	var errs []error
+	if v.opts.EKSClusterName != "" {
+		vpcID, err := v.fromEKS(ctx)
+		if err == nil {
+			return vpcID, VPCDiscoveryEKS, nil
+		}
+		klog.V(2).Infof("Unable to discover vpc with method %s: %v", VPCDiscoveryEKS, err)
+		errs = append(errs, fmt.Errorf("%s: %w", VPCDiscoveryEKS, err))
+		vpcID, err = v.fromEC2Tags(ctx)
+		if err == nil {
+			return vpcID, VPCDiscoveryEC2Tags, nil
+		}
+		klog.V(2).Infof("Unable to discover vpc with method %s: %v", VPCDiscoveryEC2Tags, err)
+		errs = append(errs, fmt.Errorf("%s: %w", VPCDiscoveryEC2Tags, err))
+	}
+	vpcID, err := vpcIDFromIMDS(ctx, v.sess)
+	if err == nil {
+		return vpcID, VPCDiscoveryIMDS, nil
+	}
+	errs = append(errs, fmt.Errorf("%s: %w", VPCDiscoveryIMDS, err))
+	return "", "", fmt.Errorf("unable to discover the cluster's vpc, configure it explicitly: %w", utilerrors.NewAggregate(errs))
+}
+
+// fromEKS returns the VPC of the EKS cluster
+func (v *VPC) fromEKS(ctx context.Context) (string, error) {
+	out, err := eks.New(v.sess).DescribeClusterWithContext(ctx, &eks.DescribeClusterInput{Name: aws.String(v.opts.EKSClusterName)})
+	if err != nil {
+		return "", fmt.Errorf("unable to describe eks cluster %s: %w", v.opts.EKSClusterName, err)
+	}
+	if out.Cluster.ResourcesVpcConfig == nil || aws.StringValue(out.Cluster.ResourcesVpcConfig.VpcId) == "" {
+		return "", fmt.Errorf("eks cluster %s has no vpc", v.opts.EKSClusterName)
+	}
+	return aws.StringValue(out.Cluster.ResourcesVpcConfig.VpcId), nil
+}
+
+// fromEC2Tags returns the VPC tagged with the cluster tag, or else the VPC of the running instances tagged with it
+func (v *VPC) fromEC2Tags(ctx context.Context) (string, error) {
+	tagFilter := &ec2.Filter{Name: aws.String("tag-key"), Values: []*string{aws.String(fmt.Sprintf("kubernetes.io/cluster/%s", v.opts.EKSClusterName))}}
+	api := ec2.New(v.sess)
+	vpcsOut, err := api.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{Filters: []*ec2.Filter{tagFilter}})
+	if err != nil {
+		return "", fmt.Errorf("unable to describe vpcs: %w", err)
+	}
+	if len(vpcsOut.Vpcs) > 1 {
+		return "", fmt.Errorf("found %d vpcs tagged %s", len(vpcsOut.Vpcs), aws.StringValue(tagFilter.Values[0]))
+	}
+	if len(vpcsOut.Vpcs) == 1 {
+		return aws.StringValue(vpcsOut.Vpcs[0].VpcId), nil
+	}
+	vpcIDs := map[string]struct{}{}
+	if err := api.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{Filters: []*ec2.Filter{
+		tagFilter,
+		{Name: aws.String("instance-state-name"), Values: []*string{aws.String(ec2.InstanceStateNameRunning)}},
+	}}, func(dio *ec2.DescribeInstancesOutput, _ bool) bool {
+		for _, reservation := range dio.Reservations {
+			for _, instance := range reservation.Instances {
+				vpcIDs[aws.StringValue(instance.VpcId)] = struct{}{}
+			}
+		}
+		return true
+	}); err != nil {
+		return "", fmt.Errorf("unable to describe instances: %w", err)
+	}
+	if len(vpcIDs) != 1 {
+		return "", fmt.Errorf("found %d vpcs of running instances tagged %s", len(vpcIDs), aws.StringValue(tagFilter.Values[0]))
+	}
+	for vpcID := range vpcIDs {
+		return vpcID, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,149 @@
+package zone
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// maxChangeBatchRecords is the number of ResourceRecord elements Route 53 accepts in one change batch, where the
+// elements of an UPSERT count twice
+const maxChangeBatchRecords = 1000
+
+// syncRequest is the single request of the zone controller. Every reconcile syncs the whole cluster, so events of
+// all Pods, Services and EndpointSlices are coalesced into it.
+var syncRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: "zone"}}
+
+// batchHandler enqueues syncRequest once the batching window of the first pending event has passed, or as soon as
+// MaxBatchSize events are pending, so a burst of events such as a rollout is synced together. The changes of a sync
+// are split into change batches by applyChanges.
+type batchHandler struct {
+	window       time.Duration
+	maxBatchSize int64
+	// pending counts the events since the last reconcile started
+	pending *int64
+}
+
+var _ handler.EventHandler = batchHandler{}
+
+func (b batchHandler) Create(_ event.CreateEvent, q workqueue.RateLimitingInterface) { b.enqueue(q) }
+
+func (b batchHandler) Update(_ event.UpdateEvent, q workqueue.RateLimitingInterface) { b.enqueue(q) }
+
+func (b batchHandler) Delete(_ event.DeleteEvent, q workqueue.RateLimitingInterface) { b.enqueue(q) }
+
+func (b batchHandler) Generic(_ event.GenericEvent, q workqueue.RateLimitingInterface) { b.enqueue(q) }
+
+func (b batchHandler) enqueue(q workqueue.RateLimitingInterface) {
+	pending := atomic.AddInt64(b.pending, 1)
+	if b.window <= 0 || (b.maxBatchSize > 0 && pending >= b.maxBatchSize) {
+		q.Add(syncRequest)
+		return
+	}
+	// the delaying queue keeps the earliest time a waiting request is ready at, so later events join the window
+	// opened by the first one rather than extending it
+	q.AddAfter(syncRequest, b.window)
+}
+
+// applyChanges applies changes to the private hosted zone in as few change batches as Route 53 accepts, or logs them
+// in a dry-run. The changes of a name and its owner record stay in one batch, so that replacing the record sets of a
+// name and claiming it are atomic.
+func (d *Reconciler) applyChanges(ctx context.Context, changes []*route53.Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if d.isDryRun() {
+		d.logPlan(changes)
+		return nil
+	}
+	for _, batch := range splitChanges(changes, maxChangeBatchRecords) {
+		start := time.Now()
+		_, err := d.r53.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: d.phz.Id,
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: batch,
+			},
+		})
+		observeChangeBatch(batchActions(batch), start)
+		d.recordPublishEvents(batch, err)
+		if err != nil {
+			return fmt.Errorf("unable to apply %d change(s) to private hosted zone %s: %w", len(batch), *d.phz.Name, err)
+		}
+	}
+	return nil
+}
+
+// splitChanges splits changes into batches of at most limit ResourceRecord elements, keeping the changes of a name
+// and its owner record together in the order they were planned
+func splitChanges(changes []*route53.Change, limit int) [][]*route53.Change {
+	var names []string
+	groups := map[string][]*route53.Change{}
+	for _, change := range changes {
+		name := aws.StringValue(change.ResourceRecordSet.Name)
+		if owned, ok := ownedRecordName(name); ok {
+			name = owned
+		}
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], change)
+	}
+	var batches [][]*route53.Change
+	var batch []*route53.Change
+	size := 0
+	for _, name := range names {
+		groupSize := 0
+		for _, change := range groups[name] {
+			groupSize += changeSize(change)
+		}
+		if len(batch) > 0 && size+groupSize > limit {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, groups[name]...)
+		size += groupSize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// changeSize returns the number of ResourceRecord elements a change counts against the limit of a change batch
+func changeSize(change *route53.Change) int {
+	// alias records carry no ResourceRecord elements but still count as one
+	size := len(change.ResourceRecordSet.ResourceRecords)
+	if size == 0 {
+		size = 1
+	}
+	if aws.StringValue(change.Action) == route53.ChangeActionUpsert {
+		size *= 2
+	}
+	return size
+}
+
+// batchActions returns the actions of the changes in a batch, e.g. "DELETE,UPSERT", to label its metrics
+func batchActions(changes []*route53.Change) string {
+	seen := map[string]struct{}{}
+	var actions []string
+	for _, change := range changes {
+		action := aws.StringValue(change.Action)
+		if _, ok := seen[action]; !ok {
+			seen[action] = struct{}{}
+			actions = append(actions, action)
+		}
+	}
+	sort.Strings(actions)
+	return strings.Join(actions, ",")
+}
@@ -0,0 +1,166 @@
+package zone
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	klog "k8s.io/klog/v2"
+)
+
+// Cleanup deletes the records this cluster owns in the private hosted zone and its CIDR collection, for uninstalling k53.
+// Ownership is the same as when reconciling: the simple A and AAAA records claimed by the cluster's owner records, and
+// the routed records whose set identifier carries the cluster name. With deleteZone, the hosted zone is deleted too
+// once no other cluster publishes records in it, otherwise only the cluster's VPC is disassociated from it.
+// The zone's DryRunTag and SyncPolicyTag apply as when reconciling: a dry-run only logs what would be cleaned up, and
+// sync policies other than SyncPolicySync keep everything.
+func (d *Reconciler) Cleanup(ctx context.Context, deleteZone bool) error {
+	phz, err := d.findPrivateHostedZone(ctx)
+	if err != nil {
+		return err
+	}
+	if phz == nil {
+		klog.Infof("Private hosted zone %s does not exist, nothing to clean up", d.phzName)
+		if d.syncPolicy != SyncPolicySync {
+			return nil
+		}
+		return d.deleteCidrCollection(ctx)
+	}
+	d.phz = phz
+	if err := d.refreshZoneTags(ctx); err != nil {
+		return err
+	}
+	if policy := d.effectiveSyncPolicy(); policy != SyncPolicySync {
+		klog.Infof("Not cleaning up private hosted zone %s with sync policy %s", d.phzName, policy)
+		return nil
+	}
+	existingRecords, err := d.ListResourceRecords(ctx)
+	if err != nil {
+		return err
+	}
+	deletes, deletedRecords := d.PlanDeletions(ctx, existingRecords)
+	if err := d.applyChanges(ctx, deletes); err != nil {
+		return err
+	}
+	if d.isDryRun() {
+		klog.Infof("Dry-run, not deleting the cidr collection of cluster %s or changing private hosted zone %s", d.clusterName, d.phzName)
+		return nil
+	}
+	klog.Infof("Deleted %d DNS resource record(s) owned by cluster %s", len(deletedRecords), d.clusterName)
+	if err := d.deleteCidrCollection(ctx); err != nil {
+		return err
+	}
+	if !deleteZone {
+		return nil
+	}
+
+	remainingRecords, err := d.ListResourceRecords(ctx)
+	if err != nil {
+		return err
+	}
+	if len(remainingRecords) == 0 && len(d.ownerRecords) == 0 {
+		if _, err := d.r53.DeleteHostedZoneWithContext(ctx, &route53.DeleteHostedZoneInput{Id: phz.Id}); err != nil {
+			return fmt.Errorf("unable to delete private hosted zone %s: %w", d.phzName, err)
+		}
+		klog.Infof("Deleted private hosted zone %s", d.phzName)
+		return nil
+	}
+	klog.Infof("Keeping private hosted zone %s, %d record(s) of other clusters remain", d.phzName, len(remainingRecords))
+	return d.disassociateVPC(ctx, phz)
+}
+
+// findPrivateHostedZone returns the private hosted zone without creating it, or nil if it does not exist
+func (d *Reconciler) findPrivateHostedZone(ctx context.Context) (*route53.HostedZone, error) {
+	hzOut, err := d.r53.ListHostedZonesByNameWithContext(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName: aws.String(d.phzName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list route 53 hosted zones: %w", err)
+	}
+	if len(hzOut.HostedZones) > 0 && *hzOut.HostedZones[0].Name == d.phzName {
+		return hzOut.HostedZones[0], nil
+	}
+	return nil, nil
+}
+
+// disassociateVPC disassociates the cluster's VPC from the hosted zone, unless it is the last VPC associated with it,
+// which Route 53 does not allow
+func (d *Reconciler) disassociateVPC(ctx context.Context, phz *route53.HostedZone) error {
+	vpcID, err := d.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
+	}
+	out, err := d.r53.GetHostedZoneWithContext(ctx, &route53.GetHostedZoneInput{Id: phz.Id})
+	if err != nil {
+		return fmt.Errorf("unable to get private hosted zone %s: %w", d.phzName, err)
+	}
+	associated := false
+	for _, vpc := range out.VPCs {
+		if aws.StringValue(vpc.VPCId) == vpcID {
+			associated = true
+		}
+	}
+	if !associated || len(out.VPCs) < 2 {
+		return nil
+	}
+	if _, err := d.r53.DisassociateVPCFromHostedZoneWithContext(ctx, &route53.DisassociateVPCFromHostedZoneInput{
+		HostedZoneId: phz.Id,
+		VPC:          &route53.VPC{VPCId: aws.String(vpcID), VPCRegion: d.sess.Config.Region},
+	}); err != nil {
+		return fmt.Errorf("unable to disassociate vpc %s from private hosted zone %s: %w", vpcID, d.phzName, err)
+	}
+	klog.Infof("Disassociated vpc %s from private hosted zone %s", vpcID, d.phzName)
+	return nil
+}
+
+// deleteCidrCollection deletes the CIDR collection of the cluster, whose locations have to be removed first
+func (d *Reconciler) deleteCidrCollection(ctx context.Context) error {
+	collectionName := fmt.Sprintf("k53-%s", d.clusterName)
+	if d.isDryRun() {
+		klog.Infof("Dry-run, not deleting route 53 cidr collection %s", collectionName)
+		return nil
+	}
+	var collection *route53.CollectionSummary
+	if err := d.r53.ListCidrCollectionsPagesWithContext(ctx, &route53.ListCidrCollectionsInput{}, func(lcco *route53.ListCidrCollectionsOutput, _ bool) bool {
+		for _, c := range lcco.CidrCollections {
+			if aws.StringValue(c.Name) == collectionName {
+				collection = c
+				return false
+			}
+		}
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to list route 53 cidr collections: %w", err)
+	}
+	if collection == nil {
+		return nil
+	}
+	var changes []*route53.CidrCollectionChange
+	if err := d.r53.ListCidrBlocksPagesWithContext(ctx, &route53.ListCidrBlocksInput{CollectionId: collection.Id}, func(lcbo *route53.ListCidrBlocksOutput, _ bool) bool {
+		for _, block := range lcbo.CidrBlocks {
+			changes = append(changes, &route53.CidrCollectionChange{
+				Action:       aws.String(route53.CidrCollectionChangeActionDeleteIfExists),
+				LocationName: block.LocationName,
+				CidrList:     []*string{block.CidrBlock},
+			})
+		}
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to list cidr blocks of route 53 cidr collection %s: %w", collectionName, err)
+	}
+	if len(changes) > 0 {
+		if _, err := d.r53.ChangeCidrCollectionWithContext(ctx, &route53.ChangeCidrCollectionInput{
+			Id:      collection.Id,
+			Changes: changes,
+		}); err != nil {
+			return fmt.Errorf("unable to empty route 53 cidr collection %s: %w", collectionName, err)
+		}
+	}
+	if _, err := d.r53.DeleteCidrCollectionWithContext(ctx, &route53.DeleteCidrCollectionInput{Id: collection.Id}); err != nil {
+		return fmt.Errorf("unable to delete route 53 cidr collection %s: %w", collectionName, err)
+	}
+	d.cidrCollection = nil
+	klog.Infof("Deleted route 53 cidr collection %s", collectionName)
+	return nil
+}
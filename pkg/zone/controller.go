@@ -5,56 +5,268 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"regexp"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/route53"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	k53session "github.com/bwagner5/k53/pkg/session"
 )
 
-var phzName = "cluster-test.local."
+// Options configures the zone Reconciler
+type Options struct {
+	// ClusterName identifies the records owned by this cluster when several clusters publish into the same hosted zone.
+	// Simple records are claimed with a TXT owner record per name, routed records carry it in their set identifier.
+	ClusterName string
+	// ZoneName is the name of the private hosted zone records are published in
+	ZoneName string
+	// PodNameTemplate is the text/template rendering pod record names relative to the zone, see RecordNameData
+	PodNameTemplate string
+	// ServiceNameTemplate is the text/template rendering service record names relative to the zone, see RecordNameData
+	ServiceNameTemplate string
+	// InvalidNamePolicy is either InvalidNamePolicyReject or InvalidNamePolicySanitize
+	InvalidNamePolicy string
+	// AssumeRole is the role managing the hosted zone when it lives in another account than the cluster.
+	// The hosted zone has to exist in that account, the cluster's VPC is associated with it across accounts.
+	AssumeRole k53session.RoleOptions
+	// ZoneRoles overrides AssumeRole for the zones it holds, keyed by zone name. A role without a session name uses
+	// the session name of AssumeRole.
+	ZoneRoles map[string]k53session.RoleOptions
+	// VPC is the cluster's VPC, discovered from EC2 IMDS if nil
+	VPC *k53session.VPC
+	// DryRun computes the changes to the private hosted zone and logs them instead of applying them.
+	// Dry-run can also be enabled for a single zone with its DryRunTag.
+	DryRun bool
+	// SyncPolicy is one of SyncPolicySync, SyncPolicyUpsertOnly or SyncPolicyCreateOnly, defaulting to SyncPolicySync.
+	// It can be overridden for a single zone with its SyncPolicyTag.
+	SyncPolicy string
+	// BatchWindow delays syncing after an event, so that the events of a burst such as a rollout are synced together
+	// in one Route 53 change batch. Zero syncs every event right away.
+	BatchWindow time.Duration
+	// MaxBatchSize syncs as soon as this many events are pending, before the batch window ends. Zero disables it.
+	// It counts Kubernetes events rather than record changes, the changes of a sync are split into as many Route 53
+	// change batches as its size limits require.
+	MaxBatchSize int
+	// MaxDeleteCount and MaxDeletePercent limit the records deleted per reconcile, zero disables a limit. Deletions
+	// exceeding a limit are withheld, as are all deletions of a reconcile that listed no pods or no services, or that
+	// ran before the informer cache synced.
+	MaxDeleteCount   int
+	MaxDeletePercent int
+	// MinDeletesForPercent is the number of deletions from which MaxDeletePercent is enforced, so that small zones can
+	// still lose most of their records
+	MinDeletesForPercent int
+	// ControllerPod is the Pod the controller runs in, Events about the hosted zone are recorded on it if set
+	ControllerPod types.NamespacedName
+	// AdoptRecords claims the simple records nobody owns that are named like the records of the pod and service name
+	// templates, e.g. those published before owner records were introduced, so that they are deleted once they are no
+	// longer generated. It only applies with SyncPolicySync.
+	AdoptRecords bool
+	// FQDNAnnotation writes the published names of Pods and Services to their FQDNAnnotation
+	FQDNAnnotation bool
+}
 
 type Reconciler struct {
-	client client.Client
-	r53    *route53.Route53
-	imds   *ec2metadata.EC2Metadata
-	sess   session.Session
-	phz    *route53.HostedZone
+	client         client.Client
+	r53            *route53.Route53
+	zoneSess       *session.Session
+	vpcR53         *route53.Route53
+	zoneRoleARN    string
+	ec2            *ec2.EC2
+	sess           session.Session
+	phz            *route53.HostedZone
+	vpc            *k53session.VPC
+	cidrCollection *route53.CollectionSummary
+	// cidrCollectionPlanned is set when a dry-run only planned the CIDR collection, so it is synced once applied
+	cidrCollectionPlanned bool
+	// vpcAssociated is set once the cluster's VPC is associated with a hosted zone in another account
+	vpcAssociated     bool
+	cidrLocations     map[string]struct{}
+	clusterName       string
+	phzName           string
+	podNames          *template.Template
+	serviceNames      *template.Template
+	invalidNamePolicy string
+	recorder          record.EventRecorder
+	fqdnAnnotation    bool
+	dryRun            bool
+	zoneDryRun        bool
+	syncPolicy        string
+	zoneSyncPolicy    string
+	// zoneTagsRefreshed is when zoneDryRun and zoneSyncPolicy were last read from the tags of the private hosted zone
+	zoneTagsRefreshed time.Time
+	batchWindow       time.Duration
+	maxBatchSize      int
+	pendingEvents     int64
+	maxDeleteCount    int
+	maxDeletePercent  int
+	// minDeletesForPercent is the number of deletions from which maxDeletePercent is enforced
+	minDeletesForPercent int
+	controllerPod        types.NamespacedName
+	// cacheSynced reports whether the informers of the manager's cache synced, it is nil outside of a manager
+	cacheSynced func(context.Context) bool
+	// listedPods and listedServices are the number of Pods and Services listed in the current reconcile
+	listedPods     int
+	listedServices int
+	// throttleRequeue is the delay of the last reconcile requeued because Route 53 throttled it
+	throttleRequeue time.Duration
+	// deletionsBlocked is set when the mass-deletion guard withheld the deletions of the current reconcile
+	deletionsBlocked bool
+	// owners maps the key of every record generated in the current reconcile to the Pod or Service it is generated for
+	owners map[string]*v1.ObjectReference
+	// ownerRecords maps the name of simple records to the TXT record naming the cluster owning them, as last listed
+	ownerRecords map[string]*route53.ResourceRecordSet
+	// adoptionPatterns match the names of the unowned records claimed with Options.AdoptRecords, nil if disabled
+	adoptionPatterns []*regexp.Regexp
 }
 
-func New(client client.Client, sess *session.Session) *Reconciler {
-	return &Reconciler{
-		client: client,
-		r53:    route53.New(sess),
-		imds:   ec2metadata.New(sess),
-		sess:   *sess,
+func New(client client.Client, sess *session.Session, opts Options) (*Reconciler, error) {
+	phzName := strings.ToLower(strings.TrimSuffix(opts.ZoneName, ".")) + "."
+	if err := validateName(phzName); err != nil {
+		return nil, fmt.Errorf("invalid zone name: %w", err)
+	}
+	podNames, err := parseNameTemplate("pod name", opts.PodNameTemplate)
+	if err != nil {
+		return nil, err
+	}
+	serviceNames, err := parseNameTemplate("service name", opts.ServiceNameTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var adoptionPatterns []*regexp.Regexp
+	if opts.AdoptRecords {
+		for _, tmpl := range []*template.Template{podNames, serviceNames} {
+			pattern, err := adoptionPattern(tmpl, phzName, opts.ClusterName)
+			if err != nil {
+				return nil, fmt.Errorf("unable to match adoptable %s: %w", tmpl.Name(), err)
+			}
+			adoptionPatterns = append(adoptionPatterns, pattern)
+		}
+	}
+	if opts.InvalidNamePolicy != InvalidNamePolicyReject && opts.InvalidNamePolicy != InvalidNamePolicySanitize {
+		return nil, fmt.Errorf("invalid name policy must be one of %s or %s, got %q", InvalidNamePolicyReject, InvalidNamePolicySanitize, opts.InvalidNamePolicy)
+	}
+	syncPolicy := opts.SyncPolicy
+	if syncPolicy == "" {
+		syncPolicy = SyncPolicySync
+	}
+	if err := validateSyncPolicy(syncPolicy); err != nil {
+		return nil, err
+	}
+	zoneRole := opts.AssumeRole
+	for zoneName, role := range opts.ZoneRoles {
+		if strings.ToLower(strings.TrimSuffix(zoneName, "."))+"." != phzName {
+			continue
+		}
+		if role.SessionName == "" {
+			role.SessionName = opts.AssumeRole.SessionName
+		}
+		zoneRole = role
 	}
+	zoneSess := sess
+	if zoneRole.RoleARN != "" {
+		zoneSess = k53session.AssumeRole(sess, zoneRole)
+	}
+	vpc := opts.VPC
+	if vpc == nil {
+		vpc = k53session.NewVPC(sess, k53session.VPCOptions{})
+	}
+	return &Reconciler{
+		client:               client,
+		r53:                  route53.New(zoneSess),
+		zoneSess:             zoneSess,
+		vpcR53:               route53.New(sess),
+		zoneRoleARN:          zoneRole.RoleARN,
+		ec2:                  ec2.New(sess),
+		sess:                 *sess,
+		vpc:                  vpc,
+		clusterName:          opts.ClusterName,
+		phzName:              phzName,
+		podNames:             podNames,
+		serviceNames:         serviceNames,
+		adoptionPatterns:     adoptionPatterns,
+		invalidNamePolicy:    opts.InvalidNamePolicy,
+		fqdnAnnotation:       opts.FQDNAnnotation,
+		dryRun:               opts.DryRun,
+		syncPolicy:           syncPolicy,
+		batchWindow:          opts.BatchWindow,
+		maxBatchSize:         opts.MaxBatchSize,
+		maxDeleteCount:       opts.MaxDeleteCount,
+		maxDeletePercent:     opts.MaxDeletePercent,
+		minDeletesForPercent: opts.MinDeletesForPercent,
+		controllerPod:        opts.ControllerPod,
+	}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (d *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		Named("zone").
-		For(&v1.Pod{}).
-		Watches(&source.Kind{Type: &v1.Service{}}, &handler.EnqueueRequestForObject{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
-		Complete(d)
+	d.recorder = mgr.GetEventRecorderFor("k53-zone")
+	d.cacheSynced = mgr.GetCache().WaitForCacheSync
+	// the builder enqueues a request per object, events are batched into a single request instead
+	c, err := controller.New("zone", mgr, controller.Options{Reconciler: d})
+	if err != nil {
+		return err
+	}
+	events := batchHandler{window: d.batchWindow, maxBatchSize: int64(d.maxBatchSize), pending: &d.pendingEvents}
+	if err := c.Watch(&source.Kind{Type: &v1.Pod{}}, events, predicate.GenerationChangedPredicate{}); err != nil {
+		return err
+	}
+	// routing policies are configured through annotations which do not bump the generation
+	if err := c.Watch(&source.Kind{Type: &v1.Service{}}, events,
+		predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})); err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &discoveryv1.EndpointSlice{}}, events, predicate.GenerationChangedPredicate{})
 }
 
 func (d *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	if err := d.CreatePrivateHostedZone(ctx); err != nil {
+	atomic.StoreInt64(&d.pendingEvents, 0)
+	if d.zoneRoleARN != "" {
+		if err := k53session.VerifyCredentials(ctx, d.zoneSess); err != nil {
+			d.recordControllerEvent(v1.EventTypeWarning, "AssumeRoleFailed", fmt.Sprintf("Unable to assume role %s for private hosted zone %s: %v", d.zoneRoleARN, d.phzName, err))
+			return ctrl.Result{}, fmt.Errorf("assuming role %s for the Route 53 private hosted zone: %w", d.zoneRoleARN, err)
+		}
+	}
+	err := d.CreatePrivateHostedZone(ctx)
+	if isThrottled(err) {
+		return d.requeueThrottled(err)
+	}
+	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("creating Route 53 private hosted zone: %w", err)
 	}
+	err = d.refreshZoneTags(ctx)
+	if isThrottled(err) {
+		return d.requeueThrottled(err)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// the association is made once the zone's tags are known, so that a dry-run tag applies to it
+	err = d.associateVPCAcrossAccounts(ctx)
+	if isThrottled(err) {
+		return d.requeueThrottled(err)
+	}
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("associating the vpc with the Route 53 private hosted zone: %w", err)
+	}
+	d.owners = map[string]*v1.ObjectReference{}
+	d.deletionsBlocked = false
 
 	podRecords, err := d.GeneratePodARecords(ctx)
 	if err != nil {
@@ -63,30 +275,64 @@ func (d *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	klog.V(10).Infof("Pod Records: %v", d.prettyPrintRecordSets(podRecords))
 
 	serviceRecords, err := d.GenerateServiceARecords(ctx)
+	// topology aware records create and update the zone's CIDR collection
+	if isThrottled(err) {
+		return d.requeueThrottled(err)
+	}
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("generating service A records, %w", err)
 	}
 	klog.V(10).Infof("Service Records: %v", d.prettyPrintRecordSets(serviceRecords))
 
+	wildcardRecords, err := d.GenerateWildcardRecords(ctx, podRecords, serviceRecords)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("generating wildcard records, %w", err)
+	}
+	klog.V(10).Infof("Wildcard Records: %v", d.prettyPrintRecordSets(wildcardRecords))
+
 	existingRecords, err := d.ListResourceRecords(ctx)
+	if isThrottled(err) {
+		return d.requeueThrottled(err)
+	}
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("listing existing records from Route 53 private hosted zone, %w", err)
 	}
 	klog.V(5).Infof("Found %d existing records", len(existingRecords))
+	d.observeRecords(existingRecords, map[string]map[string]*route53.ResourceRecordSet{
+		recordSourcePod:      podRecords,
+		recordSourceService:  serviceRecords,
+		recordSourceWildcard: wildcardRecords,
+	})
 
-	updated, err := d.UpsertRecords(ctx, existingRecords, podRecords, serviceRecords)
-	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("upserting private hosted zone records, %w", err)
+	upserts := d.PlanUpserts(existingRecords, podRecords, serviceRecords, wildcardRecords)
+	deletes, deletedRecords := d.PlanDeletions(ctx, existingRecords, podRecords, serviceRecords, wildcardRecords)
+	// upserts and deletions go out together, in as few change batches as possible
+	err = d.applyChanges(ctx, append(upserts, deletes...))
+	if isThrottled(err) {
+		return d.requeueThrottled(err)
 	}
-	klog.V(5).Infof("Upserted %d records", updated)
-
-	deletedRecords, err := d.DeleteOldRecords(ctx, existingRecords, podRecords, serviceRecords)
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("delete old records from private hosted zone, %w", err)
+		return ctrl.Result{}, fmt.Errorf("syncing private hosted zone records, %w", err)
 	}
-	if len(deletedRecords) > 0 {
-		klog.Infof("Deleted %d DNS resource record(s) that no longer exist in the cluster", len(deletedRecords))
-		klog.V(10).Infof("Deleted Records: %v", d.prettyPrintRecordSets(deletedRecords))
+	updated := len(upserts)
+	d.throttleRequeue = 0
+	// a dry-run only logs its plan, nothing was published
+	if !d.isDryRun() {
+		klog.V(5).Infof("Upserted %d records", updated)
+		reconcileChanges.WithLabelValues(route53.ChangeActionUpsert).Observe(float64(updated))
+		reconcileChanges.WithLabelValues(route53.ChangeActionDelete).Observe(float64(len(deletedRecords)))
+		if !d.deletionsBlocked {
+			lastSyncTimestamp.SetToCurrentTime()
+		}
+		if d.fqdnAnnotation {
+			if err := d.annotateFQDNs(ctx); err != nil {
+				return ctrl.Result{}, fmt.Errorf("annotating published names, %w", err)
+			}
+		}
+		if len(deletedRecords) > 0 {
+			klog.Infof("Deleted %d DNS resource record(s) that no longer exist in the cluster", len(deletedRecords))
+			klog.V(10).Infof("Deleted Records: %v", d.prettyPrintRecordSets(deletedRecords))
+		}
 	}
 
 	jitter := time.Duration(rand.Int63n(int64(2 * time.Minute)))
@@ -99,6 +345,7 @@ func (d *Reconciler) GeneratePodARecords(ctx context.Context) (map[string]*route
 	if err := d.client.List(ctx, &podList); err != nil {
 		return nil, fmt.Errorf("unable to fetch Pods: %w", err)
 	}
+	d.listedPods = len(podList.Items)
 	for _, pod := range podList.Items {
 		if pod.Status.PodIP == "" {
 			continue
@@ -114,10 +361,25 @@ func (d *Reconciler) GeneratePodARecords(ctx context.Context) (map[string]*route
 				recordType = "AAAA"
 			}
 
-			podIPHostname := strings.ReplaceAll(podIP.IP, ".", "-")
-			key := fmt.Sprintf("%s.%s.pod.%s", podIPHostname, pod.Namespace, phzName)
-			dnsRecords[key] = &route53.ResourceRecordSet{
-				Name: &key,
+			name, err := renderName(d.podNames, RecordNameData{
+				Name:        pod.Name,
+				Namespace:   pod.Namespace,
+				Labels:      pod.Labels,
+				Annotations: pod.Annotations,
+				IP:          podIP.IP,
+				ClusterName: d.clusterName,
+			}, d.phzName)
+			if err != nil {
+				klog.Errorf("invalid record name for pod %s/%s: %v", pod.Namespace, pod.Name, err)
+				d.recorder.Eventf(&pod, v1.EventTypeWarning, "InvalidRecordName", "Not publishing record: %v", err)
+				continue
+			}
+			name, ok := d.checkName(&pod, name)
+			if !ok {
+				continue
+			}
+			rs := &route53.ResourceRecordSet{
+				Name: aws.String(name),
 				Type: aws.String(recordType),
 				TTL:  aws.Int64(60),
 				ResourceRecords: []*route53.ResourceRecord{
@@ -126,6 +388,7 @@ func (d *Reconciler) GeneratePodARecords(ctx context.Context) (map[string]*route
 					},
 				},
 			}
+			d.claim(dnsRecords, &pod, rs)
 		}
 	}
 	return dnsRecords, nil
@@ -137,11 +400,32 @@ func (d *Reconciler) GenerateServiceARecords(ctx context.Context) (map[string]*r
 	if err := d.client.List(ctx, &svcList); err != nil {
 		return nil, fmt.Errorf("unable to fetch Services: %w", err)
 	}
+	d.listedServices = len(svcList.Items)
 	for _, svc := range svcList.Items {
 		clusterIP := svc.Spec.ClusterIP
-		key := fmt.Sprintf("%s.%s.svc.%s", svc.Name, svc.Namespace, phzName)
-		dnsRecords[key] = &route53.ResourceRecordSet{
-			Name: &key,
+		if net.ParseIP(clusterIP) == nil {
+			klog.V(5).Infof("not publishing service %s/%s without a cluster IP", svc.Namespace, svc.Name)
+			continue
+		}
+		name, err := renderName(d.serviceNames, RecordNameData{
+			Name:        svc.Name,
+			Namespace:   svc.Namespace,
+			Labels:      svc.Labels,
+			Annotations: svc.Annotations,
+			IP:          clusterIP,
+			ClusterName: d.clusterName,
+		}, d.phzName)
+		if err != nil {
+			klog.Errorf("invalid record name for service %s/%s: %v", svc.Namespace, svc.Name, err)
+			d.recorder.Eventf(&svc, v1.EventTypeWarning, "InvalidRecordName", "Not publishing record: %v", err)
+			continue
+		}
+		name, ok := d.checkName(&svc, name)
+		if !ok {
+			continue
+		}
+		rs := &route53.ResourceRecordSet{
+			Name: aws.String(name),
 			Type: aws.String("A"),
 			TTL:  aws.Int64(60),
 			ResourceRecords: []*route53.ResourceRecord{
@@ -150,42 +434,172 @@ func (d *Reconciler) GenerateServiceARecords(ctx context.Context) (map[string]*r
 				},
 			},
 		}
+		policy, err := parseRoutingPolicy(&svc, d.phzName)
+		if err != nil {
+			klog.Errorf("invalid routing policy for service %s/%s, publishing a simple record: %v", svc.Namespace, svc.Name, err)
+			d.recorder.Eventf(&svc, v1.EventTypeWarning, "InvalidRoutingPolicy", "Publishing a simple record: %v", err)
+		}
+		if policy != nil && policy.hostname != "" {
+			if hostname, ok := d.checkName(&svc, policy.hostname); ok {
+				policy.hostname = hostname
+			} else {
+				policy = nil
+			}
+		}
+		if policy != nil && policy.topologyAware {
+			topologyName := name
+			if policy.hostname != "" {
+				topologyName = policy.hostname
+				d.claim(dnsRecords, &svc, rs)
+			}
+			topologyRecords, err := d.TopologyRecords(ctx, &svc, topologyName)
+			if err != nil {
+				return nil, fmt.Errorf("generating topology aware records for service %s/%s: %w", svc.Namespace, svc.Name, err)
+			}
+			for _, trs := range topologyRecords {
+				d.claim(dnsRecords, &svc, trs)
+			}
+			continue
+		}
+		if policy != nil {
+			target := rs
+			if policy.hostname != "" {
+				target = &route53.ResourceRecordSet{
+					Name:            aws.String(policy.hostname),
+					Type:            rs.Type,
+					TTL:             rs.TTL,
+					ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(clusterIP)}},
+				}
+			}
+			// records are claimed once the policy set their set identifier, which is part of the record key
+			policy.apply(target, d.setIdentifier(&svc))
+			if target != rs {
+				d.claim(dnsRecords, &svc, target)
+			}
+		}
+		d.claim(dnsRecords, &svc, rs)
 	}
 	return dnsRecords, nil
 }
 
-func (d *Reconciler) UpsertRecords(ctx context.Context, existingRecords map[string]*route53.ResourceRecordSet, recordSets ...map[string]*route53.ResourceRecordSet) (int, error) {
+// PlanUpserts returns the changes publishing the generated record sets that are missing from the zone or differ from it
+func (d *Reconciler) PlanUpserts(existingRecords map[string]*route53.ResourceRecordSet, recordSets ...map[string]*route53.ResourceRecordSet) []*route53.Change {
+	policy := d.effectiveSyncPolicy()
+	action := route53.ChangeActionUpsert
+	if policy == SyncPolicyCreateOnly {
+		action = route53.ChangeActionCreate
+	}
 	var changeSet []*route53.Change
+	routed := map[string]bool{}
+	unclaimed := map[string]struct{}{}
+	// create-only leaves the names holding records alone, claiming them would let a later sync delete those records
+	existingNames := map[string]struct{}{}
+	if policy == SyncPolicyCreateOnly {
+		for _, rs := range existingRecords {
+			existingNames[*rs.Name] = struct{}{}
+		}
+	}
 	for _, records := range recordSets {
 		for _, recordSet := range records {
 			rs := recordSet
-			if existingRecord, ok := existingRecords[*rs.Name]; ok && d.IsRecordSetEqual(existingRecord, rs) {
+			if !d.mayPublish(rs) {
+				owner, _ := d.recordOwner(*rs.Name)
+				klog.Errorf("not publishing %s, it is owned by cluster %q", *rs.Name, owner)
+				if ref, ok := d.owners[recordKey(rs)]; ok {
+					d.recorder.Eventf(ref, v1.EventTypeWarning, "NameConflict", "Not publishing %s, it is owned by cluster %q", strings.TrimSuffix(*rs.Name, "."), owner)
+				}
+				continue
+			}
+			routed[fmt.Sprintf("%s %s", *rs.Name, *rs.Type)] = rs.SetIdentifier != nil
+			existingRecord, exists := existingRecords[recordKey(rs)]
+			if exists && policy == SyncPolicyCreateOnly {
+				continue
+			}
+			if _, ok := d.ownerRecords[*rs.Name]; !ok && rs.SetIdentifier == nil {
+				if _, ok := existingNames[*rs.Name]; !ok {
+					unclaimed[*rs.Name] = struct{}{}
+				}
+			}
+			if exists && d.IsRecordSetEqual(existingRecord, rs) {
 				continue
 			}
 			changeSet = append(changeSet, &route53.Change{
-				Action:            aws.String(route53.ChangeActionUpsert),
+				Action:            aws.String(action),
 				ResourceRecordSet: rs,
 			})
 		}
 	}
-	if len(changeSet) == 0 {
-		return 0, nil
+	// a name can not hold simple and routed record sets at the same time,
+	// so record sets switching between the two are replaced within the same change batch.
+	// Sync policies that never delete records keep the existing record sets and skip the switch instead.
+	switching := map[string]struct{}{}
+	for key, existingRecord := range existingRecords {
+		nameType := fmt.Sprintf("%s %s", *existingRecord.Name, *existingRecord.Type)
+		isRouted, ok := routed[nameType]
+		if !ok || isRouted == (existingRecord.SetIdentifier != nil) {
+			continue
+		}
+		if existingRecord.SetIdentifier != nil && !d.ownsSetIdentifier(*existingRecord.SetIdentifier) || !d.mayPublish(existingRecord) {
+			continue
+		}
+		if policy != SyncPolicySync {
+			switching[nameType] = struct{}{}
+			continue
+		}
+		changeSet = append([]*route53.Change{{
+			Action:            aws.String(route53.ChangeActionDelete),
+			ResourceRecordSet: existingRecord,
+		}}, changeSet...)
+		delete(existingRecords, key)
 	}
-	if _, err := d.r53.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
-		HostedZoneId: d.phz.Id,
-		ChangeBatch: &route53.ChangeBatch{
-			Changes: changeSet,
-		},
-	}); err != nil {
-		return 0, fmt.Errorf("unable to update private hosted zone %s with %d records: %w", *d.phz.Name, len(changeSet), err)
+	if len(switching) > 0 {
+		var allowed []*route53.Change
+		for _, change := range changeSet {
+			nameType := fmt.Sprintf("%s %s", *change.ResourceRecordSet.Name, *change.ResourceRecordSet.Type)
+			if _, ok := switching[nameType]; ok {
+				klog.Errorf("not publishing %s, replacing its existing records is not allowed with sync policy %s", nameType, policy)
+				continue
+			}
+			allowed = append(allowed, change)
+		}
+		changeSet = allowed
+	}
+	// unowned records of this cluster's name templates are claimed, so that they are deleted once no longer generated
+	if policy == SyncPolicySync {
+		for _, rs := range existingRecords {
+			if d.adoptable(rs) {
+				klog.Infof("adopting %s %s", *rs.Type, *rs.Name)
+				unclaimed[*rs.Name] = struct{}{}
+			}
+		}
+	}
+	// owner records are created rather than upserted, so that a cluster racing for the same name fails the batch
+	// and backs off once it sees the other cluster's owner record on the next reconcile
+	for name := range unclaimed {
+		changeSet = append(changeSet, &route53.Change{
+			Action:            aws.String(route53.ChangeActionCreate),
+			ResourceRecordSet: d.ownerRecordSet(name),
+		})
 	}
-	return len(changeSet), nil
+	return changeSet
 }
 
 func (d *Reconciler) IsRecordSetEqual(rsa *route53.ResourceRecordSet, rsb *route53.ResourceRecordSet) bool {
 	if *rsa.Type != *rsb.Type || *rsa.TTL != *rsb.TTL || len(rsa.ResourceRecords) != len(rsb.ResourceRecords) {
 		return false
 	}
+	if aws.StringValue(rsa.SetIdentifier) != aws.StringValue(rsb.SetIdentifier) ||
+		aws.Int64Value(rsa.Weight) != aws.Int64Value(rsb.Weight) ||
+		(rsa.Weight == nil) != (rsb.Weight == nil) ||
+		aws.StringValue(rsa.Failover) != aws.StringValue(rsb.Failover) ||
+		aws.StringValue(rsa.HealthCheckId) != aws.StringValue(rsb.HealthCheckId) {
+		return false
+	}
+	if (rsa.CidrRoutingConfig == nil) != (rsb.CidrRoutingConfig == nil) ||
+		(rsa.CidrRoutingConfig != nil && (aws.StringValue(rsa.CidrRoutingConfig.CollectionId) != aws.StringValue(rsb.CidrRoutingConfig.CollectionId) ||
+			aws.StringValue(rsa.CidrRoutingConfig.LocationName) != aws.StringValue(rsb.CidrRoutingConfig.LocationName))) {
+		return false
+	}
 	ra := rsa.ResourceRecords
 	sort.Slice(ra, func(i, j int) bool {
 		return *ra[i].Value < *ra[j].Value
@@ -202,9 +616,22 @@ func (d *Reconciler) IsRecordSetEqual(rsa *route53.ResourceRecordSet, rsb *route
 	return true
 }
 
-func (d *Reconciler) DeleteOldRecords(ctx context.Context, existingRecords map[string]*route53.ResourceRecordSet, recordMaps ...map[string]*route53.ResourceRecordSet) (map[string]*route53.ResourceRecordSet, error) {
+// PlanDeletions returns the changes deleting the owned record sets that are not generated anymore, along with the
+// record sets they delete. Nothing is deleted when the mass-deletion guard trips.
+func (d *Reconciler) PlanDeletions(ctx context.Context, existingRecords map[string]*route53.ResourceRecordSet, recordMaps ...map[string]*route53.ResourceRecordSet) ([]*route53.Change, map[string]*route53.ResourceRecordSet) {
+	if policy := d.effectiveSyncPolicy(); policy != SyncPolicySync {
+		klog.V(5).Infof("Not deleting records with sync policy %s", policy)
+		return nil, map[string]*route53.ResourceRecordSet{}
+	}
 	recordsToDelete := existingRecords
-	for existingRecord := range existingRecords {
+	owned := 0
+	for existingRecord, rs := range existingRecords {
+		// records of other clusters, and records nobody claimed, are left alone
+		if !d.ownsRecord(rs) {
+			delete(recordsToDelete, existingRecord)
+			continue
+		}
+		owned++
 		for _, recordSets := range recordMaps {
 			if _, ok := recordSets[existingRecord]; ok {
 				delete(recordsToDelete, existingRecord)
@@ -222,34 +649,55 @@ func (d *Reconciler) DeleteOldRecords(ctx context.Context, existingRecords map[s
 		}
 		deleteSet = append(deleteSet, change)
 	}
-	if len(deleteSet) > 0 {
-		if _, err := d.r53.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
-			HostedZoneId: d.phz.Id,
-			ChangeBatch: &route53.ChangeBatch{
-				Changes: deleteSet,
-			},
-		}); err != nil {
-			return nil, fmt.Errorf("unable to delete resource record sets for hosted zone %s: %v", *d.phz.Name, err)
+	// cleanup deletes every owned record without listing the cluster
+	if !d.allowDeletions(ctx, len(deleteSet), owned, len(recordMaps) > 0) {
+		d.deletionsBlocked = true
+		return nil, map[string]*route53.ResourceRecordSet{}
+	}
+	// the owner record of a name goes once this cluster no longer publishes simple records under it
+	published := map[string]struct{}{}
+	for _, recordSets := range recordMaps {
+		for _, rs := range recordSets {
+			if rs.SetIdentifier == nil {
+				published[*rs.Name] = struct{}{}
+			}
 		}
 	}
-	return recordsToDelete, nil
+	for name, ownerRecord := range d.ownerRecords {
+		if owner, _ := d.recordOwner(name); owner != d.clusterName {
+			continue
+		}
+		if _, ok := published[name]; !ok {
+			deleteSet = append(deleteSet, &route53.Change{
+				Action:            aws.String(route53.ChangeActionDelete),
+				ResourceRecordSet: ownerRecord,
+			})
+		}
+	}
+	return deleteSet, recordsToDelete
 }
 
 func (d *Reconciler) ListResourceRecords(ctx context.Context) (map[string]*route53.ResourceRecordSet, error) {
 	existingRecords := map[string]*route53.ResourceRecordSet{}
+	ownerRecords := map[string]*route53.ResourceRecordSet{}
 	if err := d.r53.ListResourceRecordSetsPagesWithContext(ctx, &route53.ListResourceRecordSetsInput{
 		HostedZoneId: d.phz.Id,
 	}, func(lrrso *route53.ListResourceRecordSetsOutput, _ bool) bool {
 		for _, recordSet := range lrrso.ResourceRecordSets {
 			r := recordSet
+			r.Name = aws.String(unescapeName(*r.Name))
 			if *r.Type == "A" || *r.Type == "AAAA" {
-				existingRecords[*r.Name] = r
+				existingRecords[recordKey(r)] = r
+			}
+			if name, ok := ownedRecordName(*r.Name); ok && *r.Type == "TXT" {
+				ownerRecords[name] = r
 			}
 		}
 		return true
 	}); err != nil {
 		return nil, fmt.Errorf("unable to list resource record sets for hosted zone %s: %w", *d.phz.Name, err)
 	}
+	d.ownerRecords = ownerRecords
 	return existingRecords, nil
 }
 
@@ -262,17 +710,23 @@ func (d *Reconciler) CreatePrivateHostedZone(ctx context.Context) error {
 		return fmt.Errorf("unable to get vpc id %w", err)
 	}
 	hzOut, err := d.r53.ListHostedZonesByNameWithContext(ctx, &route53.ListHostedZonesByNameInput{
-		DNSName: aws.String(phzName),
+		DNSName: aws.String(d.phzName),
 	})
 	if err != nil {
-		return fmt.Errorf("unable to list route 53 hosted zones: %v", err)
+		return fmt.Errorf("unable to list route 53 hosted zones: %w", err)
 	}
-	if len(hzOut.HostedZones) > 0 && *hzOut.HostedZones[0].Name == phzName {
+	if len(hzOut.HostedZones) > 0 && *hzOut.HostedZones[0].Name == d.phzName {
 		d.phz = hzOut.HostedZones[0]
 		return nil
 	}
+	if d.zoneRoleARN != "" {
+		return fmt.Errorf("private hosted zone %s does not exist in the account of role %s, it has to be created there", d.phzName, d.zoneRoleARN)
+	}
+	if d.dryRun {
+		return fmt.Errorf("private hosted zone %s does not exist, a dry-run does not create it", d.phzName)
+	}
 	phzOutput, err := d.r53.CreateHostedZoneWithContext(ctx, &route53.CreateHostedZoneInput{
-		Name: aws.String(phzName),
+		Name: aws.String(d.phzName),
 		VPC: &route53.VPC{
 			VPCRegion: d.sess.Config.Region,
 			VPCId:     aws.String(vpcID),
@@ -283,26 +737,74 @@ func (d *Reconciler) CreatePrivateHostedZone(ctx context.Context) error {
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("unable to create a route 53 private hosted zone: %v", err)
+		return fmt.Errorf("unable to create a route 53 private hosted zone: %w", err)
 	}
 	d.phz = phzOutput.HostedZone
 	return nil
 }
 
-func (d *Reconciler) getVPCID(ctx context.Context) (string, error) {
-	macsResp, err := d.imds.GetMetadataWithContext(ctx, "/network/interfaces/macs")
-	if err != nil {
-		return "", fmt.Errorf("unable to retrieve vpc-id from network interfaces: %v", err)
+// associateVPCAcrossAccounts associates the cluster's VPC with a hosted zone in the account of the assumed role.
+// The zone's account authorizes the association, which is then made from the VPC's account. A dry-run only logs it.
+func (d *Reconciler) associateVPCAcrossAccounts(ctx context.Context) error {
+	if d.zoneRoleARN == "" || d.vpcAssociated {
+		return nil
 	}
-	macs := strings.Split(macsResp, "\n")
-	if len(macs) == 0 {
-		return "", fmt.Errorf("unable to identify primary network interface: %v", err)
+	phz := d.phz
+	vpcID, err := d.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
 	}
-	vpcID, err := d.imds.GetMetadataWithContext(ctx, fmt.Sprintf("/network/interfaces/macs/%s/vpc-id", macs[0]))
+	out, err := d.r53.GetHostedZoneWithContext(ctx, &route53.GetHostedZoneInput{Id: phz.Id})
 	if err != nil {
-		return "", fmt.Errorf("unable to retrieve vpc-id from primary network interface: %v", err)
+		return fmt.Errorf("unable to get private hosted zone %s: %w", d.phzName, err)
+	}
+	for _, vpc := range out.VPCs {
+		if aws.StringValue(vpc.VPCId) == vpcID {
+			d.vpcAssociated = true
+			return nil
+		}
+	}
+	if d.isDryRun() {
+		klog.Infof("Dry-run, not associating vpc %s with private hosted zone %s in the account of role %s", vpcID, d.phzName, d.zoneRoleARN)
+		return nil
+	}
+	vpc := &route53.VPC{VPCId: aws.String(vpcID), VPCRegion: d.sess.Config.Region}
+	if _, err := d.r53.CreateVPCAssociationAuthorizationWithContext(ctx, &route53.CreateVPCAssociationAuthorizationInput{
+		HostedZoneId: phz.Id,
+		VPC:          vpc,
+	}); err != nil {
+		return fmt.Errorf("unable to authorize the association of vpc %s with private hosted zone %s: %w", vpcID, d.phzName, err)
+	}
+	if _, err := d.vpcR53.AssociateVPCWithHostedZoneWithContext(ctx, &route53.AssociateVPCWithHostedZoneInput{
+		HostedZoneId: phz.Id,
+		VPC:          vpc,
+	}); err != nil {
+		return fmt.Errorf("unable to associate vpc %s with private hosted zone %s: %w", vpcID, d.phzName, err)
+	}
+	// the authorization is only needed to make the association
+	if _, err := d.r53.DeleteVPCAssociationAuthorizationWithContext(ctx, &route53.DeleteVPCAssociationAuthorizationInput{
+		HostedZoneId: phz.Id,
+		VPC:          vpc,
+	}); err != nil {
+		klog.Errorf("unable to delete the authorization to associate vpc %s with private hosted zone %s: %v", vpcID, d.phzName, err)
+	}
+	klog.Infof("Associated vpc %s with private hosted zone %s in the account of role %s", vpcID, d.phzName, d.zoneRoleARN)
+	d.vpcAssociated = true
+	return nil
+}
+
+func (d *Reconciler) getVPCID(ctx context.Context) (string, error) {
+	return d.vpc.ID(ctx)
+}
+
+// recordKey uniquely identifies a resource record set within a hosted zone.
+// Record sets with a routing policy share a name and type and are told apart by their set identifier.
+func recordKey(rs *route53.ResourceRecordSet) string {
+	key := fmt.Sprintf("%s %s", *rs.Name, *rs.Type)
+	if rs.SetIdentifier != nil {
+		key = fmt.Sprintf("%s %s", key, *rs.SetIdentifier)
 	}
-	return vpcID, nil
+	return key
 }
 
 func (d *Reconciler) prettyPrintRecordSets(recordSets map[string]*route53.ResourceRecordSet) string {
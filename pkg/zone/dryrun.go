@@ -0,0 +1,57 @@
+package zone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/prometheus/client_golang/prometheus"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// DryRunTag enables dry-run for a single private hosted zone when its value is "true", see Options.DryRun
+const DryRunTag = "k53.bwag.me/dry-run"
+
+var (
+	dryRunEnabled = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "dry_run",
+		Help:      "Whether the last reconcile was a dry-run, 1 if it only planned changes to the private hosted zone.",
+	})
+	plannedChanges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "dry_run_planned_changes",
+		Help:      "Number of record set changes the last dry-run reconcile would have made by action.",
+	}, []string{"action"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(dryRunEnabled, plannedChanges)
+}
+
+func (d *Reconciler) isDryRun() bool {
+	return d.dryRun || d.zoneDryRun
+}
+
+// logPlan logs the changes a dry-run would make to the private hosted zone instead of applying them
+func (d *Reconciler) logPlan(changes []*route53.Change) {
+	var planned []string
+	for _, change := range changes {
+		plannedChanges.WithLabelValues(aws.StringValue(change.Action)).Inc()
+		rs := change.ResourceRecordSet
+		var values []string
+		for _, r := range rs.ResourceRecords {
+			values = append(values, aws.StringValue(r.Value))
+		}
+		change := fmt.Sprintf("%s %s %s -> %s", aws.StringValue(change.Action), aws.StringValue(rs.Type), aws.StringValue(rs.Name), strings.Join(values, ","))
+		if rs.SetIdentifier != nil {
+			change = fmt.Sprintf("%s (%s)", change, aws.StringValue(rs.SetIdentifier))
+		}
+		planned = append(planned, change)
+	}
+	klog.Infof("Dry-run, not applying %d change(s) to private hosted zone %s: %s", len(changes), d.phzName, strings.Join(planned, "; "))
+}
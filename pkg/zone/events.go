@@ -0,0 +1,145 @@
+package zone
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	v1 "k8s.io/api/core/v1"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FQDNAnnotation lists the fully qualified names published for a Pod or Service, comma separated.
+// It is only written when enabled with Options.FQDNAnnotation.
+const FQDNAnnotation = "k53.bwag.me/fqdns"
+
+// claim adds a record set generated for a Pod or Service to records, unless another object already claimed the same
+// record, in which case a NameConflict Event is recorded on obj and false is returned
+func (d *Reconciler) claim(records map[string]*route53.ResourceRecordSet, obj client.Object, rs *route53.ResourceRecordSet) bool {
+	key := recordKey(rs)
+	ref := objectReference(obj)
+	if owner, ok := d.owners[key]; ok && owner.UID != ref.UID {
+		klog.Errorf("not publishing %s for %s %s, it is already published for %s %s/%s", *rs.Name, kind(obj), client.ObjectKeyFromObject(obj), strings.ToLower(owner.Kind), owner.Namespace, owner.Name)
+		d.recorder.Eventf(obj, v1.EventTypeWarning, "NameConflict", "Not publishing %s, it is already published for %s %s/%s", strings.TrimSuffix(*rs.Name, "."), strings.ToLower(owner.Kind), owner.Namespace, owner.Name)
+		return false
+	}
+	d.owners[key] = ref
+	records[key] = rs
+	return true
+}
+
+// recordPublishEvents records a Published or PublishFailed Event on each object whose records were created or upserted by changes
+func (d *Reconciler) recordPublishEvents(changes []*route53.Change, err error) {
+	// throttled changes are retried with the next batch, they did not fail yet
+	if isThrottled(err) {
+		return
+	}
+	names := map[*v1.ObjectReference][]string{}
+	for _, change := range changes {
+		if aws.StringValue(change.Action) == route53.ChangeActionDelete {
+			continue
+		}
+		if owner, ok := d.owners[recordKey(change.ResourceRecordSet)]; ok {
+			names[owner] = append(names[owner], strings.TrimSuffix(*change.ResourceRecordSet.Name, "."))
+		}
+	}
+	for owner, ownerNames := range names {
+		ownerNames = uniqueSorted(ownerNames)
+		if err != nil {
+			d.recorder.Eventf(owner, v1.EventTypeWarning, "PublishFailed", "Unable to publish %s: %v", strings.Join(ownerNames, ", "), err)
+			continue
+		}
+		d.recorder.Eventf(owner, v1.EventTypeNormal, "Published", "Published %s", strings.Join(ownerNames, ", "))
+	}
+}
+
+// annotateFQDNs sets FQDNAnnotation on every Pod and Service publishing records, and removes it from the others
+func (d *Reconciler) annotateFQDNs(ctx context.Context) error {
+	names := map[string][]string{}
+	for key, owner := range d.owners {
+		ownerKey := fmt.Sprintf("%s %s/%s", owner.Kind, owner.Namespace, owner.Name)
+		names[ownerKey] = append(names[ownerKey], strings.TrimSuffix(strings.SplitN(key, " ", 2)[0], "."))
+	}
+	var objects []client.Object
+	var podList v1.PodList
+	if err := d.client.List(ctx, &podList); err != nil {
+		return fmt.Errorf("unable to fetch Pods: %w", err)
+	}
+	for i := range podList.Items {
+		objects = append(objects, &podList.Items[i])
+	}
+	var svcList v1.ServiceList
+	if err := d.client.List(ctx, &svcList); err != nil {
+		return fmt.Errorf("unable to fetch Services: %w", err)
+	}
+	for i := range svcList.Items {
+		objects = append(objects, &svcList.Items[i])
+	}
+	for _, obj := range objects {
+		ref := objectReference(obj)
+		fqdns := strings.Join(uniqueSorted(names[fmt.Sprintf("%s %s/%s", ref.Kind, ref.Namespace, ref.Name)]), ",")
+		current, annotated := obj.GetAnnotations()[FQDNAnnotation]
+		if current == fqdns && (annotated || fqdns == "") {
+			continue
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		annotations := obj.GetAnnotations()
+		if fqdns == "" {
+			delete(annotations, FQDNAnnotation)
+		} else {
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[FQDNAnnotation] = fqdns
+		}
+		obj.SetAnnotations(annotations)
+		if err := d.client.Patch(ctx, obj, patch); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("unable to annotate %s %s: %w", kind(obj), client.ObjectKeyFromObject(obj), err)
+		}
+	}
+	return nil
+}
+
+// recordControllerEvent records an Event on the controller's own Pod, for failures not tied to a Pod or Service
+func (d *Reconciler) recordControllerEvent(eventType string, reason string, message string) {
+	if d.controllerPod.Name == "" || d.recorder == nil {
+		return
+	}
+	d.recorder.Event(&v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Namespace:  d.controllerPod.Namespace,
+		Name:       d.controllerPod.Name,
+	}, eventType, reason, message)
+}
+
+// objectReference references a Pod or Service for Events, without holding on to the object
+func objectReference(obj client.Object) *v1.ObjectReference {
+	kindName := "Pod"
+	if _, ok := obj.(*v1.Service); ok {
+		kindName = "Service"
+	}
+	return &v1.ObjectReference{
+		APIVersion:      "v1",
+		Kind:            kindName,
+		Namespace:       obj.GetNamespace(),
+		Name:            obj.GetName(),
+		UID:             obj.GetUID(),
+		ResourceVersion: obj.GetResourceVersion(),
+	}
+}
+
+func uniqueSorted(values []string) []string {
+	sort.Strings(values)
+	unique := values[:0]
+	for _, value := range values {
+		if len(unique) == 0 || value != unique[len(unique)-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,73 @@
+package zone
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	deletionGuardTrips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "deletion_guard_trips_total",
+		Help:      "Number of reconciles whose deletions were withheld by the mass-deletion guard by reason.",
+	}, []string{"reason"})
+	blockedDeletions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "blocked_deletions",
+		Help:      "Number of record sets the mass-deletion guard kept from being deleted in the last reconcile.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(deletionGuardTrips, blockedDeletions)
+}
+
+// allowDeletions checks the mass-deletion guard before deleting records, deletions are withheld if the informer cache
+// is not synced, if no pods or no services were listed, or if they exceed Options.MaxDeleteCount or Options.MaxDeletePercent of the owned records.
+// A cluster always runs the controller's pod and the kubernetes service, so an empty listing is a broken one. Cleanup
+// lists nothing and deletes every owned record, it passes listed false to skip the listing check.
+// Records are still upserted when the guard trips, so the zone falls back to upsert-only until the cause is resolved.
+func (d *Reconciler) allowDeletions(ctx context.Context, deletions int, owned int, listed bool) bool {
+	blockedDeletions.Set(0)
+	if deletions == 0 {
+		return true
+	}
+	var reason, message string
+	switch {
+	case d.cacheSynced != nil && !d.waitForCacheSync(ctx):
+		reason = "CacheNotSynced"
+		message = fmt.Sprintf("Not deleting %d record(s), the informer cache is not synced", deletions)
+	case listed && (d.listedPods == 0 || d.listedServices == 0):
+		reason = "EmptyListing"
+		message = fmt.Sprintf("Not deleting %d record(s), %d pod(s) and %d service(s) were listed", deletions, d.listedPods, d.listedServices)
+	case d.maxDeleteCount > 0 && deletions > d.maxDeleteCount:
+		reason = "MaxDeleteCount"
+		message = fmt.Sprintf("Not deleting %d record(s), more than the limit of %d per reconcile", deletions, d.maxDeleteCount)
+	case d.maxDeletePercent > 0 && deletions >= d.minDeletesForPercent && deletions*100 > d.maxDeletePercent*owned:
+		reason = "MaxDeletePercent"
+		message = fmt.Sprintf("Not deleting %d of %d record(s), more than the limit of %d%% per reconcile", deletions, owned, d.maxDeletePercent)
+	default:
+		return true
+	}
+	klog.Errorf("Mass-deletion guard tripped for private hosted zone %s: %s", d.phzName, message)
+	deletionGuardTrips.WithLabelValues(reason).Inc()
+	blockedDeletions.Set(float64(deletions))
+	d.recordControllerEvent(v1.EventTypeWarning, "DeletionGuard", message)
+	return false
+}
+
+// waitForCacheSync waits a bounded time for the informers of the manager's cache to sync, a partially synced cache
+// lists only some of the cluster's objects
+func (d *Reconciler) waitForCacheSync(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return d.cacheSynced(ctx)
+}
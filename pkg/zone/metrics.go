@@ -0,0 +1,98 @@
+package zone
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Record sources label the record metrics with the kind of object a record is generated from
+const (
+	recordSourcePod      = "pod"
+	recordSourceService  = "service"
+	recordSourceWildcard = "wildcard"
+	// recordSourceNone labels records in the hosted zone that are not generated from any object
+	recordSourceNone = "none"
+)
+
+var (
+	desiredRecords = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "desired_records",
+		Help:      "Number of record sets generated from the cluster by type and source.",
+	}, []string{"type", "source"})
+	actualRecords = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "actual_records",
+		Help:      "Number of A and AAAA record sets in the private hosted zone by type and the source generating them, none if they are not generated.",
+	}, []string{"type", "source"})
+	reconcileChanges = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "reconcile_record_changes",
+		Help:      "Number of record sets upserted or deleted per reconcile.",
+		Buckets:   []float64{0, 1, 5, 10, 50, 100, 500, 1000},
+	}, []string{"action"})
+	changeBatchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "change_batch_duration_seconds",
+		Help:      "Latency of Route 53 change batches by the actions they contain, e.g. DELETE,UPSERT.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"action"})
+	lastSyncTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "last_sync_timestamp_seconds",
+		Help:      "Unix time of the last reconcile that synced every record, time() minus it is the time since the last successful sync.",
+	})
+	driftRecords = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "k53",
+		Subsystem: "zone",
+		Name:      "drift_records",
+		Help:      "Number of record sets that differed between the cluster and the private hosted zone when last reconciled.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(desiredRecords, actualRecords, reconcileChanges, changeBatchDuration, lastSyncTimestamp, driftRecords)
+}
+
+// observeRecords updates the desired and actual record counts, and the drift between them
+func (d *Reconciler) observeRecords(existingRecords map[string]*route53.ResourceRecordSet, recordsBySource map[string]map[string]*route53.ResourceRecordSet) {
+	desiredRecords.Reset()
+	actualRecords.Reset()
+	drift := 0
+	for source, records := range recordsBySource {
+		for key, rs := range records {
+			desiredRecords.WithLabelValues(aws.StringValue(rs.Type), source).Inc()
+			if existingRecord, ok := existingRecords[key]; !ok || !d.IsRecordSetEqual(existingRecord, rs) {
+				drift++
+			}
+		}
+	}
+	for key, rs := range existingRecords {
+		source := recordSourceNone
+		for s, records := range recordsBySource {
+			if _, ok := records[key]; ok {
+				source = s
+				break
+			}
+		}
+		actualRecords.WithLabelValues(aws.StringValue(rs.Type), source).Inc()
+		if source == recordSourceNone && d.ownsRecord(rs) {
+			drift++
+		}
+	}
+	driftRecords.Set(float64(drift))
+}
+
+// observeChangeBatch records the latency of a Route 53 change batch with the given actions started at start
+func observeChangeBatch(actions string, start time.Time) {
+	changeBatchDuration.WithLabelValues(actions).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,217 @@
+package zone
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// InvalidNamePolicyReject skips records whose generated name is not a valid DNS name
+	InvalidNamePolicyReject = "reject"
+	// InvalidNamePolicySanitize rewrites invalid generated names into valid DNS names where possible
+	InvalidNamePolicySanitize = "sanitize"
+
+	// DefaultPodNameTemplate publishes pods as <dashed-ip>.<namespace>.pod.<zone>
+	DefaultPodNameTemplate = `{{ dashed .IP }}.{{ .Namespace }}.pod`
+	// DefaultServiceNameTemplate publishes services as <name>.<namespace>.svc.<zone>
+	DefaultServiceNameTemplate = `{{ .Name }}.{{ .Namespace }}.svc`
+)
+
+// RecordNameData is the data available to record name templates
+type RecordNameData struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	// IP is the pod IP of a pod, or the cluster IP of a service
+	IP          string
+	ClusterName string
+}
+
+var (
+	templateFuncs = template.FuncMap{
+		"dashed":  dashed,
+		"lower":   strings.ToLower,
+		"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"default": func(def, s string) string {
+			if s == "" {
+				return def
+			}
+			return s
+		},
+	}
+	// Route 53 allows more characters, but record names are kept to host name characters and "_"
+	labelPattern = regexp.MustCompile(`^([a-z0-9_]([a-z0-9_-]*[a-z0-9_])?|\*)$`)
+	// invalidLabelChars matches the characters sanitizeName replaces with "-"
+	invalidLabelChars = regexp.MustCompile(`[^a-z0-9_*-]`)
+)
+
+// parseNameTemplate parses a record name template and checks that it renders, e.g. that it only refers to known fields.
+// Rendered names depend on the labels and annotations of each object, so they are validated as they are rendered.
+func parseNameTemplate(name string, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s template: %w", name, err)
+	}
+	sample := RecordNameData{
+		Name:        "example",
+		Namespace:   "default",
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+		IP:          "10.0.0.1",
+		ClusterName: "cluster",
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("validating %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// renderName renders a record name template into a fully qualified name within the zone.
+// The rendered name still needs to be validated with checkName.
+func renderName(tmpl *template.Template, data RecordNameData, zone string) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering %s template: %w", tmpl.Name(), err)
+	}
+	return qualify(b.String(), zone), nil
+}
+
+// checkName validates a generated record name, sanitizing it when the invalid name policy allows it.
+// A warning Event is recorded on the object the name was generated for when the name is invalid.
+func (d *Reconciler) checkName(obj client.Object, name string) (string, bool) {
+	err := validateName(name)
+	if err == nil {
+		return name, true
+	}
+	if d.invalidNamePolicy == InvalidNamePolicySanitize {
+		if sanitized := sanitizeName(name); validateName(sanitized) == nil {
+			klog.V(5).Infof("sanitized record name %q of %s %s to %q", name, kind(obj), client.ObjectKeyFromObject(obj), sanitized)
+			d.recorder.Eventf(obj, v1.EventTypeWarning, "InvalidRecordName", "Publishing sanitized record name %s: %v", sanitized, err)
+			return sanitized, true
+		}
+	}
+	klog.Errorf("invalid record name for %s %s: %v", kind(obj), client.ObjectKeyFromObject(obj), err)
+	d.recorder.Eventf(obj, v1.EventTypeWarning, "InvalidRecordName", "Not publishing record: %v", err)
+	return "", false
+}
+
+// validateName checks that a fully qualified name fits DNS length limits and host name characters
+func validateName(name string) error {
+	if len(strings.TrimSuffix(name, ".")) > 253 {
+		return fmt.Errorf("name %q is longer than 253 characters", name)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) > 63 {
+			return fmt.Errorf("label %q of name %q is longer than 63 characters", label, name)
+		}
+		if !labelPattern.MatchString(label) {
+			return fmt.Errorf("label %q of name %q must be non-empty and consist of lower case alphanumeric characters, '-' or '_'", label, name)
+		}
+	}
+	return nil
+}
+
+// sanitizeName lower cases a name, replaces characters that are not allowed in host names with "-",
+// trims leading and trailing "-" from each label and truncates labels to 63 characters
+func sanitizeName(name string) string {
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".")
+	for i, label := range labels {
+		label = strings.Trim(invalidLabelChars.ReplaceAllString(label, "-"), "-")
+		if len(label) > 63 {
+			label = strings.TrimRight(label[:63], "-")
+		}
+		labels[i] = label
+	}
+	return strings.Join(labels, ".") + "."
+}
+
+// dashed returns the host name label form of an IP address, e.g. 10-0-0-1 or 2001-db8--1.
+// IPv6 addresses starting or ending with "::" are padded with a 0 so the label does not start or end with "-".
+func dashed(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return strings.ReplaceAll(v4.String(), ".", "-")
+	}
+	v6 := parsed.String()
+	if strings.HasPrefix(v6, "::") {
+		v6 = "0" + v6
+	}
+	if strings.HasSuffix(v6, "::") {
+		v6 = v6 + "0"
+	}
+	return strings.ReplaceAll(v6, ":", "-")
+}
+
+// kind returns the kind of a Pod or Service for log messages, since typed objects from the client carry no TypeMeta
+func kind(obj client.Object) string {
+	switch obj.(type) {
+	case *v1.Pod:
+		return "pod"
+	case *v1.Service:
+		return "service"
+	default:
+		return strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+}
+
+// qualify returns the fully qualified name of a name relative to the zone
+func qualify(name string, zone string) string {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if name+"." == zone || strings.HasSuffix(name+".", "."+zone) {
+		return name + "."
+	}
+	return fmt.Sprintf("%s.%s", name, zone)
+}
+
+// SearchDomains returns the search domains resolving the services of a namespace by <name> and <name>.<namespace>,
+// like the cluster domain search path of kube-dns, followed by the zone. It fails for service name templates that do
+// not render the service name as the first label of a name that only depends on the namespace and cluster name, since
+// no search domain could resolve the services by name then.
+func SearchDomains(serviceNameTemplate string, zoneName string, clusterName string, namespace string) ([]string, error) {
+	tmpl, err := parseNameTemplate("service name", serviceNameTemplate)
+	if err != nil {
+		return nil, err
+	}
+	zone := strings.ToLower(strings.TrimSuffix(zoneName, ".")) + "."
+	const service = "k53-service"
+	var namespaceDomain string
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		name, err := renderName(tmpl, RecordNameData{
+			Name:        service,
+			Namespace:   namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+			IP:          ip,
+			ClusterName: clusterName,
+		}, zone)
+		if err != nil {
+			return nil, err
+		}
+		domain, ok := cutPrefix(name, service+".")
+		if !ok || domain == zone {
+			return nil, fmt.Errorf("service name template %q does not render the service name as the first label below a namespace domain", serviceNameTemplate)
+		}
+		if namespaceDomain != "" && domain != namespaceDomain {
+			return nil, fmt.Errorf("service name template %q renders names that depend on more than the service name and namespace", serviceNameTemplate)
+		}
+		namespaceDomain = domain
+	}
+	searches := []string{strings.TrimSuffix(namespaceDomain, ".")}
+	if domain, ok := cutPrefix(namespaceDomain, namespace+"."); ok && domain != zone {
+		searches = append(searches, strings.TrimSuffix(domain, "."))
+	}
+	return append(searches, strings.TrimSuffix(zone, ".")), nil
+}
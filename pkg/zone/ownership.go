@@ -0,0 +1,132 @@
+package zone
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// ownerRecordPrefix prefixes the names of the TXT records marking which cluster owns the simple records of a name.
+// Routed records carry their owner in the set identifier instead, see Reconciler.setIdentifier.
+const ownerRecordPrefix = "_k53-owner"
+
+// ownerRecordName returns the name of the owner record of the simple records named name. A "*" is only a wildcard as
+// the leftmost label, so the owner record of a wildcard name replaces it.
+func ownerRecordName(name string) string {
+	if strings.HasPrefix(name, "*.") {
+		return fmt.Sprintf("%s-wildcard.%s", ownerRecordPrefix, strings.TrimPrefix(name, "*."))
+	}
+	return fmt.Sprintf("%s.%s", ownerRecordPrefix, name)
+}
+
+// ownedRecordName returns the name of the records an owner record is named for, and false if name is no owner record
+func ownedRecordName(name string) (string, bool) {
+	if strings.HasPrefix(name, ownerRecordPrefix+"-wildcard.") {
+		return "*." + strings.TrimPrefix(name, ownerRecordPrefix+"-wildcard."), true
+	}
+	if strings.HasPrefix(name, ownerRecordPrefix+".") {
+		return strings.TrimPrefix(name, ownerRecordPrefix+"."), true
+	}
+	return "", false
+}
+
+// ownerRecordSet returns the owner record claiming the simple records named name for this cluster
+func (d *Reconciler) ownerRecordSet(name string) *route53.ResourceRecordSet {
+	return &route53.ResourceRecordSet{
+		Name: aws.String(ownerRecordName(name)),
+		Type: aws.String("TXT"),
+		TTL:  aws.Int64(300),
+		ResourceRecords: []*route53.ResourceRecord{
+			{
+				Value: aws.String(fmt.Sprintf(`"heritage=k53,k53/owner=%s"`, d.clusterName)),
+			},
+		},
+	}
+}
+
+// recordOwner returns the cluster owning the simple records named name, and false if no cluster claimed them
+func (d *Reconciler) recordOwner(name string) (string, bool) {
+	rs, ok := d.ownerRecords[name]
+	if !ok {
+		return "", false
+	}
+	for _, r := range rs.ResourceRecords {
+		for _, field := range strings.Split(strings.Trim(aws.StringValue(r.Value), `"`), ",") {
+			if owner, ok := cutPrefix(field, "k53/owner="); ok {
+				return owner, true
+			}
+		}
+	}
+	// an owner record without an owner still keeps other clusters away
+	return "", true
+}
+
+// ownsRecord returns true if the record set was published by this cluster and may be deleted by it
+func (d *Reconciler) ownsRecord(rs *route53.ResourceRecordSet) bool {
+	if rs.SetIdentifier != nil {
+		return d.ownsSetIdentifier(*rs.SetIdentifier)
+	}
+	owner, ok := d.recordOwner(*rs.Name)
+	return ok && owner == d.clusterName
+}
+
+// mayPublish returns true unless the simple records named like rs are owned by another cluster. Records nobody owns,
+// e.g. those published before owner records were introduced, are adopted by the first cluster publishing them.
+func (d *Reconciler) mayPublish(rs *route53.ResourceRecordSet) bool {
+	if rs.SetIdentifier != nil {
+		return true
+	}
+	owner, ok := d.recordOwner(*rs.Name)
+	return !ok || owner == d.clusterName
+}
+
+// adoptionPattern returns a pattern matching the names a record name template renders for any object name, namespace
+// and IP, so that records published by this cluster before owner records were introduced can be adopted. Labels and
+// annotations render empty, templates depending on them only match the names of objects without them.
+func adoptionPattern(tmpl *template.Template, zone string, clusterName string) (*regexp.Regexp, error) {
+	const name, namespace, ip = "k53adoptname", "k53adoptnamespace", "k53adoptip"
+	rendered, err := renderName(tmpl, RecordNameData{
+		Name:        name,
+		Namespace:   namespace,
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+		IP:          ip,
+		ClusterName: clusterName,
+	}, zone)
+	if err != nil {
+		return nil, err
+	}
+	pattern := regexp.QuoteMeta(rendered)
+	for _, placeholder := range []string{namespace, name, ip} {
+		pattern = strings.ReplaceAll(pattern, placeholder, `[a-z0-9_-]+`)
+	}
+	return regexp.Compile("^" + pattern + "$")
+}
+
+// adoptable returns true if the record set is a simple A or AAAA record nobody claimed, named like the records of
+// this cluster's name templates
+func (d *Reconciler) adoptable(rs *route53.ResourceRecordSet) bool {
+	if rs.SetIdentifier != nil || (aws.StringValue(rs.Type) != "A" && aws.StringValue(rs.Type) != "AAAA") {
+		return false
+	}
+	if _, ok := d.recordOwner(*rs.Name); ok {
+		return false
+	}
+	for _, pattern := range d.adoptionPatterns {
+		if pattern.MatchString(*rs.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func cutPrefix(s string, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return strings.TrimPrefix(s, prefix), true
+}
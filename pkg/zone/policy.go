@@ -0,0 +1,86 @@
+package zone
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// SyncPolicySync creates, updates and deletes records to match the cluster
+	SyncPolicySync = "sync"
+	// SyncPolicyUpsertOnly creates and updates records but never deletes them, e.g. while migrating records to k53
+	SyncPolicyUpsertOnly = "upsert-only"
+	// SyncPolicyCreateOnly only creates records that do not exist yet, never overwriting or deleting existing ones
+	SyncPolicyCreateOnly = "create-only"
+
+	// SyncPolicyTag overrides Options.SyncPolicy for a single private hosted zone
+	SyncPolicyTag = "k53.bwag.me/sync-policy"
+
+	// zoneTagsTTL is how long the tags of the private hosted zone are cached, so that a tag change applies within it
+	// without listing the tags on every reconcile
+	zoneTagsTTL = time.Minute
+)
+
+func validateSyncPolicy(policy string) error {
+	switch policy {
+	case SyncPolicySync, SyncPolicyUpsertOnly, SyncPolicyCreateOnly:
+		return nil
+	}
+	return fmt.Errorf("sync policy must be one of %s, %s or %s, got %q", SyncPolicySync, SyncPolicyUpsertOnly, SyncPolicyCreateOnly, policy)
+}
+
+// refreshZoneTags reads the DryRunTag and SyncPolicyTag of the private hosted zone, so that they can be changed per zone
+// without a restart. The tags are read again once they are older than zoneTagsTTL.
+func (d *Reconciler) refreshZoneTags(ctx context.Context) error {
+	dryRunEnabled.Set(0)
+	plannedChanges.Reset()
+	if !d.zoneTagsRefreshed.IsZero() && time.Since(d.zoneTagsRefreshed) < zoneTagsTTL {
+		if d.isDryRun() {
+			dryRunEnabled.Set(1)
+		}
+		return nil
+	}
+	out, err := d.r53.ListTagsForResourceWithContext(ctx, &route53.ListTagsForResourceInput{
+		ResourceType: aws.String(route53.TagResourceTypeHostedzone),
+		ResourceId:   aws.String(strings.TrimPrefix(aws.StringValue(d.phz.Id), "/hostedzone/")),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list tags of private hosted zone %s: %w", d.phzName, err)
+	}
+	d.zoneDryRun = false
+	d.zoneSyncPolicy = ""
+	if out.ResourceTagSet != nil {
+		for _, tag := range out.ResourceTagSet.Tags {
+			switch aws.StringValue(tag.Key) {
+			case DryRunTag:
+				d.zoneDryRun, _ = strconv.ParseBool(aws.StringValue(tag.Value))
+			case SyncPolicyTag:
+				if err := validateSyncPolicy(aws.StringValue(tag.Value)); err != nil {
+					klog.Errorf("invalid %s tag on private hosted zone %s, using sync policy %s: %v", SyncPolicyTag, d.phzName, d.syncPolicy, err)
+					continue
+				}
+				d.zoneSyncPolicy = aws.StringValue(tag.Value)
+			}
+		}
+	}
+	d.zoneTagsRefreshed = time.Now()
+	if d.isDryRun() {
+		dryRunEnabled.Set(1)
+	}
+	return nil
+}
+
+// effectiveSyncPolicy returns the sync policy of the private hosted zone, its SyncPolicyTag or else Options.SyncPolicy
+func (d *Reconciler) effectiveSyncPolicy() string {
+	if d.zoneSyncPolicy != "" {
+		return d.zoneSyncPolicy
+	}
+	return d.syncPolicy
+}
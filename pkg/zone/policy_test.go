@@ -0,0 +1,72 @@
+package zone
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPlanUpsertsCreateOnly(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		existing []*route53.ResourceRecordSet
+		// expected maps the name and type of every planned change to its action
+		expected map[string]string
+	}{
+		{
+			name:     "new record",
+			expected: map[string]string{"web.default.svc.zone. A": "CREATE", "_k53-owner.web.default.svc.zone. TXT": "CREATE"},
+		},
+		{
+			name:     "existing foreign record",
+			existing: []*route53.ResourceRecordSet{aRecord("web.default.svc.zone.", "A", "10.0.0.2")},
+			expected: map[string]string{},
+		},
+		{
+			name:     "existing foreign record of another type",
+			existing: []*route53.ResourceRecordSet{aRecord("web.default.svc.zone.", "AAAA", "fd00::2")},
+			expected: map[string]string{"web.default.svc.zone. A": "CREATE"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Reconciler{
+				clusterName:  "cluster",
+				phzName:      "zone.",
+				syncPolicy:   SyncPolicyCreateOnly,
+				ownerRecords: map[string]*route53.ResourceRecordSet{},
+				owners:       map[string]*v1.ObjectReference{},
+			}
+			existing := map[string]*route53.ResourceRecordSet{}
+			for _, rs := range tc.existing {
+				existing[recordKey(rs)] = rs
+			}
+			generated := aRecord("web.default.svc.zone.", "A", "10.0.0.1")
+
+			changes := d.PlanUpserts(existing, map[string]*route53.ResourceRecordSet{recordKey(generated): generated})
+
+			planned := map[string]string{}
+			for _, change := range changes {
+				planned[*change.ResourceRecordSet.Name+" "+*change.ResourceRecordSet.Type] = *change.Action
+			}
+			if len(planned) != len(tc.expected) {
+				t.Fatalf("expected changes %v, got %v", tc.expected, planned)
+			}
+			for key, action := range tc.expected {
+				if planned[key] != action {
+					t.Errorf("expected %s of %s, got changes %v", action, key, planned)
+				}
+			}
+		})
+	}
+}
+
+func aRecord(name string, recordType string, value string) *route53.ResourceRecordSet {
+	return &route53.ResourceRecordSet{
+		Name:            aws.String(name),
+		Type:            aws.String(recordType),
+		TTL:             aws.Int64(60),
+		ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(value)}},
+	}
+}
@@ -0,0 +1,100 @@
+package zone
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// HostnameAnnotation publishes a Service under an additional name in the hosted zone so that
+	// several Services, or the same Service in several clusters, can share one DNS name
+	HostnameAnnotation = "k53.bwag.me/hostname"
+	// WeightAnnotation publishes the Service as a weighted record set with the given weight (0-255)
+	WeightAnnotation = "k53.bwag.me/weight"
+	// FailoverAnnotation publishes the Service as a "primary" or "secondary" failover record set
+	FailoverAnnotation = "k53.bwag.me/failover"
+	// HealthCheckIDAnnotation associates a Route 53 health check with a weighted or failover record set
+	HealthCheckIDAnnotation = "k53.bwag.me/health-check-id"
+)
+
+// routingPolicy is the Route 53 routing policy requested through Service annotations
+type routingPolicy struct {
+	hostname      string
+	weight        *int64
+	failover      *string
+	healthCheckID *string
+	topologyAware bool
+}
+
+// parseRoutingPolicy reads the routing annotations of a Service.
+// A nil policy is returned when the Service does not request one.
+func parseRoutingPolicy(svc *v1.Service, zone string) (*routingPolicy, error) {
+	policy := &routingPolicy{}
+	if hostname, ok := svc.Annotations[HostnameAnnotation]; ok && hostname != "" {
+		policy.hostname = qualify(hostname, zone)
+	}
+	if weight, ok := svc.Annotations[WeightAnnotation]; ok {
+		w, err := strconv.ParseInt(weight, 10, 64)
+		if err != nil || w < 0 || w > 255 {
+			return nil, fmt.Errorf("%s must be an integer between 0 and 255, got %q", WeightAnnotation, weight)
+		}
+		policy.weight = aws.Int64(w)
+	}
+	if failover, ok := svc.Annotations[FailoverAnnotation]; ok {
+		failover = strings.ToUpper(failover)
+		if failover != route53.ResourceRecordSetFailoverPrimary && failover != route53.ResourceRecordSetFailoverSecondary {
+			return nil, fmt.Errorf("%s must be one of primary or secondary, got %q", FailoverAnnotation, failover)
+		}
+		policy.failover = aws.String(failover)
+	}
+	if topologyAware, ok := svc.Annotations[TopologyAwareAnnotation]; ok {
+		t, err := strconv.ParseBool(topologyAware)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a boolean, got %q", TopologyAwareAnnotation, topologyAware)
+		}
+		policy.topologyAware = t
+	}
+	if policy.weight != nil && policy.failover != nil {
+		return nil, fmt.Errorf("%s and %s are mutually exclusive", WeightAnnotation, FailoverAnnotation)
+	}
+	if policy.topologyAware && (policy.weight != nil || policy.failover != nil) {
+		return nil, fmt.Errorf("%s can not be combined with %s or %s", TopologyAwareAnnotation, WeightAnnotation, FailoverAnnotation)
+	}
+	if healthCheckID, ok := svc.Annotations[HealthCheckIDAnnotation]; ok {
+		if policy.weight == nil && policy.failover == nil {
+			return nil, fmt.Errorf("%s requires %s or %s", HealthCheckIDAnnotation, WeightAnnotation, FailoverAnnotation)
+		}
+		policy.healthCheckID = aws.String(healthCheckID)
+	}
+	if policy.hostname == "" && policy.weight == nil && policy.failover == nil && !policy.topologyAware {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// apply sets the routing policy on the record set, leaving it a simple record set when only a hostname was requested
+func (p *routingPolicy) apply(rs *route53.ResourceRecordSet, setIdentifier string) {
+	if p.weight == nil && p.failover == nil {
+		return
+	}
+	rs.SetIdentifier = aws.String(setIdentifier)
+	rs.Weight = p.weight
+	rs.Failover = p.failover
+	rs.HealthCheckId = p.healthCheckID
+}
+
+// setIdentifier returns the set identifier of a Service's routed record set, prefixed with the cluster name
+// so that each cluster only manages its own record sets for a shared name
+func (d *Reconciler) setIdentifier(svc *v1.Service) string {
+	return fmt.Sprintf("%s/%s/%s", d.clusterName, svc.Namespace, svc.Name)
+}
+
+// ownsSetIdentifier returns true if the set identifier was published by this cluster
+func (d *Reconciler) ownsSetIdentifier(setIdentifier string) bool {
+	return strings.HasPrefix(setIdentifier, d.clusterName+"/")
+}
@@ -0,0 +1,37 @@
+package zone
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	klog "k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	minThrottleRequeue = 5 * time.Second
+	maxThrottleRequeue = 2 * time.Minute
+)
+
+// isThrottled returns whether Route 53 still throttled a request once the session's retryer gave up
+func isThrottled(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && request.IsErrorThrottle(awsErr)
+}
+
+// requeueThrottled requeues a reconcile whose Route 53 requests were throttled, with a delay doubling while throttling
+// lasts. Every reconcile computes the changes from the whole cluster, so the changes that could not be made are
+// coalesced into the batch of the next reconcile instead of failing this one.
+func (d *Reconciler) requeueThrottled(err error) (ctrl.Result, error) {
+	d.throttleRequeue *= 2
+	if d.throttleRequeue < minThrottleRequeue {
+		d.throttleRequeue = minThrottleRequeue
+	}
+	if d.throttleRequeue > maxThrottleRequeue {
+		d.throttleRequeue = maxThrottleRequeue
+	}
+	klog.Infof("Route 53 is throttling requests, retrying pending changes to private hosted zone %s in %s: %v", d.phzName, d.throttleRequeue, err)
+	return ctrl.Result{RequeueAfter: d.throttleRequeue}, nil
+}
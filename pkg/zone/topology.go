@@ -0,0 +1,206 @@
+package zone
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TopologyAwareAnnotation publishes the ready endpoints of a Service grouped by availability zone so that
+	// clients resolve endpoints in their own zone, falling back to all endpoints when their zone has none
+	TopologyAwareAnnotation = "k53.bwag.me/topology-aware"
+
+	// defaultCidrLocation is the Route 53 CIDR location matching clients outside of every other location
+	defaultCidrLocation = "*"
+)
+
+// Route 53 CIDR location names are limited to 16 alphanumeric, dash or underscore characters
+var cidrLocationPattern = regexp.MustCompile(`^[0-9A-Za-z_-]{1,16}$`)
+
+// TopologyRecords generates IP-based routing record sets for a topology aware Service.
+// Route 53 latency and geolocation routing only distinguish regions, so the availability zone of a client is
+// identified by the VPC subnet its query comes from, using a CIDR collection with one location per zone.
+func (d *Reconciler) TopologyRecords(ctx context.Context, svc *v1.Service, name string) ([]*route53.ResourceRecordSet, error) {
+	if err := d.CreateCidrCollection(ctx); err != nil {
+		return nil, fmt.Errorf("creating Route 53 CIDR collection: %w", err)
+	}
+	endpointsByZone, err := d.readyEndpointsByZone(ctx, svc)
+	if err != nil {
+		return nil, err
+	}
+	var allEndpoints []string
+	var recordSets []*route53.ResourceRecordSet
+	for zone, endpoints := range endpointsByZone {
+		allEndpoints = append(allEndpoints, endpoints...)
+		if _, ok := d.cidrLocations[zone]; !ok {
+			klog.V(5).Infof("No CIDR location for zone %q of service %s/%s, its endpoints are only published in the default location", zone, svc.Namespace, svc.Name)
+			continue
+		}
+		recordSets = append(recordSets, d.cidrRecordSet(svc, name, zone, endpoints))
+	}
+	// clients in zones without ready endpoints are answered with every endpoint, or the cluster IP if there are none
+	if len(allEndpoints) == 0 {
+		allEndpoints = []string{svc.Spec.ClusterIP}
+	}
+	recordSets = append(recordSets, d.cidrRecordSet(svc, name, defaultCidrLocation, allEndpoints))
+	return recordSets, nil
+}
+
+func (d *Reconciler) cidrRecordSet(svc *v1.Service, name string, location string, ips []string) *route53.ResourceRecordSet {
+	sort.Strings(ips)
+	var resourceRecords []*route53.ResourceRecord
+	for _, ip := range ips {
+		resourceRecords = append(resourceRecords, &route53.ResourceRecord{Value: aws.String(ip)})
+	}
+	return &route53.ResourceRecordSet{
+		Name:            aws.String(name),
+		Type:            aws.String("A"),
+		TTL:             aws.Int64(60),
+		SetIdentifier:   aws.String(fmt.Sprintf("%s/%s", d.setIdentifier(svc), location)),
+		ResourceRecords: resourceRecords,
+		CidrRoutingConfig: &route53.CidrRoutingConfig{
+			CollectionId: d.cidrCollection.Id,
+			LocationName: aws.String(location),
+		},
+	}
+}
+
+// readyEndpointsByZone groups the ready IPv4 endpoints of a Service by availability zone.
+// The zone is taken from the EndpointSlice, its topology hints, or the topology label of the endpoint's node.
+func (d *Reconciler) readyEndpointsByZone(ctx context.Context, svc *v1.Service) (map[string][]string, error) {
+	var sliceList discoveryv1.EndpointSliceList
+	if err := d.client.List(ctx, &sliceList, client.InNamespace(svc.Namespace), client.MatchingLabels{
+		discoveryv1.LabelServiceName: svc.Name,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to fetch EndpointSlices for service %s/%s: %w", svc.Namespace, svc.Name, err)
+	}
+	endpointsByZone := map[string][]string{}
+	for _, slice := range sliceList.Items {
+		if slice.AddressType != discoveryv1.AddressTypeIPv4 {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			zone, err := d.endpointZone(ctx, endpoint)
+			if err != nil {
+				return nil, err
+			}
+			endpointsByZone[zone] = append(endpointsByZone[zone], endpoint.Addresses...)
+		}
+	}
+	return endpointsByZone, nil
+}
+
+func (d *Reconciler) endpointZone(ctx context.Context, endpoint discoveryv1.Endpoint) (string, error) {
+	if endpoint.Zone != nil {
+		return *endpoint.Zone, nil
+	}
+	if endpoint.Hints != nil && len(endpoint.Hints.ForZones) == 1 {
+		return endpoint.Hints.ForZones[0].Name, nil
+	}
+	if endpoint.NodeName == nil {
+		return defaultCidrLocation, nil
+	}
+	var node v1.Node
+	if err := d.client.Get(ctx, client.ObjectKey{Name: *endpoint.NodeName}, &node); err != nil {
+		return "", fmt.Errorf("unable to fetch Node %s: %w", *endpoint.NodeName, err)
+	}
+	if zone, ok := node.Labels[v1.LabelTopologyZone]; ok {
+		return zone, nil
+	}
+	return defaultCidrLocation, nil
+}
+
+// CreateCidrCollection creates the Route 53 CIDR collection mapping each availability zone of the VPC to its subnets.
+// A dry-run only logs the changes to the collection.
+func (d *Reconciler) CreateCidrCollection(ctx context.Context) error {
+	if d.cidrCollection != nil && !d.cidrCollectionPlanned {
+		return nil
+	}
+	vpcID, err := d.getVPCID(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get vpc id %w", err)
+	}
+	locations := map[string][]string{}
+	if err := d.ec2.DescribeSubnetsPagesWithContext(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}}},
+	}, func(dso *ec2.DescribeSubnetsOutput, _ bool) bool {
+		for _, subnet := range dso.Subnets {
+			zone := aws.StringValue(subnet.AvailabilityZone)
+			if !cidrLocationPattern.MatchString(zone) {
+				klog.Errorf("availability zone %q of subnet %s is not a valid Route 53 CIDR location", zone, aws.StringValue(subnet.SubnetId))
+				continue
+			}
+			locations[zone] = append(locations[zone], aws.StringValue(subnet.CidrBlock))
+		}
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to describe subnets of vpc %s: %w", vpcID, err)
+	}
+
+	collectionName := fmt.Sprintf("k53-%s", d.clusterName)
+	var collection *route53.CollectionSummary
+	if err := d.r53.ListCidrCollectionsPagesWithContext(ctx, &route53.ListCidrCollectionsInput{}, func(lcco *route53.ListCidrCollectionsOutput, _ bool) bool {
+		for _, c := range lcco.CidrCollections {
+			if aws.StringValue(c.Name) == collectionName {
+				collection = c
+				return false
+			}
+		}
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to list route 53 cidr collections: %w", err)
+	}
+	if collection == nil && d.isDryRun() {
+		klog.Infof("Dry-run, not creating route 53 cidr collection %s", collectionName)
+		collection = &route53.CollectionSummary{Name: aws.String(collectionName)}
+	}
+	if collection == nil {
+		out, err := d.r53.CreateCidrCollectionWithContext(ctx, &route53.CreateCidrCollectionInput{
+			Name:            aws.String(collectionName),
+			CallerReference: aws.String(collectionName),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create route 53 cidr collection %s: %w", collectionName, err)
+		}
+		collection = &route53.CollectionSummary{Id: out.Collection.Id, Name: out.Collection.Name}
+	}
+
+	var changes []*route53.CidrCollectionChange
+	for zone, cidrs := range locations {
+		changes = append(changes, &route53.CidrCollectionChange{
+			Action:       aws.String(route53.CidrCollectionChangeActionPut),
+			LocationName: aws.String(zone),
+			CidrList:     aws.StringSlice(cidrs),
+		})
+	}
+	if len(changes) > 0 && d.isDryRun() {
+		klog.Infof("Dry-run, not putting %d location(s) into route 53 cidr collection %s", len(changes), collectionName)
+	} else if len(changes) > 0 {
+		if _, err := d.r53.ChangeCidrCollectionWithContext(ctx, &route53.ChangeCidrCollectionInput{
+			Id:      collection.Id,
+			Changes: changes,
+		}); err != nil {
+			return fmt.Errorf("unable to update route 53 cidr collection %s: %w", collectionName, err)
+		}
+	}
+	d.cidrLocations = map[string]struct{}{}
+	for zone := range locations {
+		d.cidrLocations[zone] = struct{}{}
+	}
+	d.cidrCollection = collection
+	d.cidrCollectionPlanned = d.isDryRun()
+	return nil
+}
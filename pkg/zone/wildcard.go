@@ -0,0 +1,167 @@
+package zone
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	v1 "k8s.io/api/core/v1"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WildcardAnnotation publishes a catch-all record pointing at the Service next to its own name, e.g. *.<namespace>.svc
+// with the default service name template, answering for every name there that has no explicit record
+const WildcardAnnotation = "k53.bwag.me/wildcard"
+
+// GenerateWildcardRecords generates the wildcard records requested by Services. The wildcard replaces the first label
+// of the Service's name, so it follows the service name template. A wildcard name holds at most one wildcard, and a
+// wildcard is rejected if an explicit record has its name, or if it would also answer for names published for
+// another namespace, e.g. when the template does not put each namespace under its own name.
+func (d *Reconciler) GenerateWildcardRecords(ctx context.Context, explicitRecords ...map[string]*route53.ResourceRecordSet) (map[string]*route53.ResourceRecordSet, error) {
+	dnsRecords := map[string]*route53.ResourceRecordSet{}
+	var svcList v1.ServiceList
+	if err := d.client.List(ctx, &svcList); err != nil {
+		return nil, fmt.Errorf("unable to fetch Services: %w", err)
+	}
+	// the namespace of the object each explicit name is published for
+	explicitNames := map[string]string{}
+	for _, records := range explicitRecords {
+		for key, rs := range records {
+			namespace := ""
+			if owner, ok := d.owners[key]; ok {
+				namespace = owner.Namespace
+			}
+			explicitNames[*rs.Name] = namespace
+		}
+	}
+	// the oldest Service claiming a namespace's wildcard wins
+	sort.Slice(svcList.Items, func(i, j int) bool {
+		a, b := svcList.Items[i], svcList.Items[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return client.ObjectKeyFromObject(&a).String() < client.ObjectKeyFromObject(&b).String()
+	})
+	owners := map[string]string{}
+	for _, svc := range svcList.Items {
+		wildcard, ok := svc.Annotations[WildcardAnnotation]
+		if !ok {
+			continue
+		}
+		if enabled, err := strconv.ParseBool(wildcard); err != nil || !enabled {
+			if err != nil {
+				klog.Errorf("invalid %s annotation on service %s/%s: %q is not a boolean", WildcardAnnotation, svc.Namespace, svc.Name, wildcard)
+			}
+			continue
+		}
+		if net.ParseIP(svc.Spec.ClusterIP) == nil {
+			klog.Errorf("not publishing wildcard for service %s/%s without a cluster IP", svc.Namespace, svc.Name)
+			d.recorder.Eventf(&svc, v1.EventTypeWarning, "InvalidWildcard", "Not publishing wildcard, the service has no cluster IP")
+			continue
+		}
+		name, ok := d.wildcardName(&svc)
+		if !ok {
+			continue
+		}
+		if owner, ok := owners[name]; ok {
+			klog.Errorf("not publishing wildcard %s for service %s/%s, it is already published for service %s", name, svc.Namespace, svc.Name, owner)
+			d.recorder.Eventf(&svc, v1.EventTypeWarning, "NameConflict", "Not publishing wildcard %s, it is already published for service %s", strings.TrimSuffix(name, "."), owner)
+			continue
+		}
+		if _, ok := explicitNames[name]; ok {
+			klog.Errorf("not publishing wildcard %s for service %s/%s, an explicit record has the same name", name, svc.Namespace, svc.Name)
+			d.recorder.Eventf(&svc, v1.EventTypeWarning, "NameConflict", "Not publishing wildcard %s, an explicit record has the same name", strings.TrimSuffix(name, "."))
+			continue
+		}
+		if covered, namespace, ok := coversOtherNamespace(name, svc.Namespace, explicitNames); ok {
+			klog.Errorf("not publishing wildcard %s for service %s/%s, it covers %s published for namespace %s", name, svc.Namespace, svc.Name, covered, namespace)
+			d.recorder.Eventf(&svc, v1.EventTypeWarning, "NameConflict", "Not publishing wildcard %s, it covers %s published for namespace %s", strings.TrimSuffix(name, "."), strings.TrimSuffix(covered, "."), namespace)
+			continue
+		}
+		owners[name] = client.ObjectKeyFromObject(&svc).String()
+		rs := &route53.ResourceRecordSet{
+			Name: aws.String(name),
+			Type: aws.String("A"),
+			TTL:  aws.Int64(60),
+			ResourceRecords: []*route53.ResourceRecord{
+				{
+					Value: aws.String(svc.Spec.ClusterIP),
+				},
+			},
+		}
+		d.claim(dnsRecords, &svc, rs)
+	}
+	return dnsRecords, nil
+}
+
+// wildcardName renders the name of a Service with the service name template and replaces its first label with "*".
+// Names directly under the zone get no wildcard, since it would answer for the whole zone.
+func (d *Reconciler) wildcardName(svc *v1.Service) (string, bool) {
+	name, err := renderName(d.serviceNames, RecordNameData{
+		Name:        svc.Name,
+		Namespace:   svc.Namespace,
+		Labels:      svc.Labels,
+		Annotations: svc.Annotations,
+		IP:          svc.Spec.ClusterIP,
+		ClusterName: d.clusterName,
+	}, d.phzName)
+	if err != nil {
+		klog.Errorf("invalid record name for service %s/%s: %v", svc.Namespace, svc.Name, err)
+		d.recorder.Eventf(svc, v1.EventTypeWarning, "InvalidRecordName", "Not publishing wildcard: %v", err)
+		return "", false
+	}
+	name, ok := d.checkName(svc, name)
+	if !ok {
+		return "", false
+	}
+	parent := name[strings.Index(name, ".")+1:]
+	if parent == d.phzName {
+		klog.Errorf("not publishing wildcard for service %s/%s, its name %s is directly under the zone", svc.Namespace, svc.Name, name)
+		d.recorder.Eventf(svc, v1.EventTypeWarning, "InvalidWildcard", "Not publishing wildcard, the name %s is directly under the zone", strings.TrimSuffix(name, "."))
+		return "", false
+	}
+	return "*." + parent, true
+}
+
+// coversOtherNamespace returns an explicit name the wildcard would answer for if it had no record, or whose
+// parents it would answer for, that is published for another namespace than the wildcard's
+func coversOtherNamespace(wildcard string, namespace string, explicitNames map[string]string) (string, string, bool) {
+	parent := strings.TrimPrefix(wildcard, "*")
+	var covered []string
+	for name, ns := range explicitNames {
+		if ns != namespace && strings.HasSuffix(name, parent) {
+			covered = append(covered, name)
+		}
+	}
+	if len(covered) == 0 {
+		return "", "", false
+	}
+	sort.Strings(covered)
+	return covered[0], explicitNames[covered[0]], true
+}
+
+// unescapeName decodes the \ddd octal escapes Route 53 uses for characters other than a-z, 0-9, "-" and "_"
+// in the record names it returns, e.g. \052 for the "*" of a wildcard record
+func unescapeName(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			if c, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}